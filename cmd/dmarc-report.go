@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dmarc"
+	"github.com/tempusbreve/cloud-init-helper/internal/reports"
+)
+
+var dmarcReportCmd = &cobra.Command{
+	Use:   "report PATH...",
+	Short: "Summarize DMARC aggregate reports",
+	Long: `Summarize RFC 7489 aggregate reports by source IP address.
+
+Each PATH is a report file (XML, gzip or zip), a mail message carrying
+reports, or a directory or maildir of them, e.g. the rua mailbox exported
+from maddy's storage.
+
+Sources that fail DMARC are flagged as unauthorized, as are sources outside
+the --authorized prefixes when those are given.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		authorized, err := dmarcOpts.Authorized()
+		cobra.CheckErr(err)
+
+		var parsed []*dmarc.Report
+		for _, path := range args {
+			payloads, err := reports.Read(path)
+			cobra.CheckErr(err)
+
+			for _, p := range payloads {
+				if p.Err != nil {
+					cmd.PrintErrf("skipping %s: %v\n", p.Source, p.Err)
+					continue
+				}
+
+				r, err := dmarc.Parse(p.Data)
+				if errors.Is(err, dmarc.ErrNotAggregateReport) {
+					continue
+				}
+				if err != nil {
+					cmd.PrintErrf("skipping %s: %v\n", p.Source, err)
+					continue
+				}
+				parsed = append(parsed, r)
+			}
+		}
+
+		summary := dmarc.Summarize(parsed, authorized)
+
+		switch dmarcOpts.format {
+		case "json":
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			cobra.CheckErr(enc.Encode(summary))
+		case "table":
+			printDMARCSummary(cmd, summary)
+		default:
+			cobra.CheckErr(fmt.Errorf("invalid format %q: expected table or json", dmarcOpts.format))
+		}
+	},
+}
+
+func printDMARCSummary(cmd *cobra.Command, s *dmarc.Summary) {
+	cmd.Printf("%d reports, %d messages: %d pass, %d fail\n", s.Reports, s.Messages, s.Pass, s.Fail)
+	if s.Reports == 0 {
+		return
+	}
+	cmd.Printf("period %s to %s\n\n", s.Begin.Format("2006-01-02"), s.End.Format("2006-01-02"))
+
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE IP\tMESSAGES\tPASS\tFAIL\tDKIM ALIGNED\tSPF ALIGNED\tQUARANTINE/REJECT\tDOMAINS\tREPORTERS\t")
+	for _, src := range s.Sources {
+		flag := ""
+		if src.Unauthorized {
+			flag = "UNAUTHORIZED"
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d/%d\t%s\t%s\t%s\n",
+			src.IP, src.Messages, src.Pass, src.Fail, src.DKIMAligned, src.SPFAligned,
+			src.Quarantined, src.Rejected, strings.Join(src.Domains, ","), strings.Join(src.Reporters, ","), flag)
+	}
+	_ = tw.Flush()
+}
+
+var dmarcOpts = dmarcOptions{format: "table"}
+
+type dmarcOptions struct {
+	format     string
+	authorized []string
+}
+
+// Authorized parses the authorized sender prefixes, accepting single
+// addresses as well as CIDR prefixes.
+func (o dmarcOptions) Authorized() ([]netip.Prefix, error) {
+	var res []netip.Prefix
+	for _, a := range o.authorized {
+		if !strings.Contains(a, "/") {
+			addr, err := netip.ParseAddr(a)
+			if err != nil {
+				return nil, fmt.Errorf("invalid authorized address %q: %w", a, err)
+			}
+			res = append(res, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		p, err := netip.ParsePrefix(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid authorized prefix %q: %w", a, err)
+		}
+		res = append(res, p.Masked())
+	}
+	return res, nil
+}
+
+func init() {
+	dmarcCmd.AddCommand(dmarcReportCmd)
+
+	dmarcReportCmd.Flags().StringVarP(&dmarcOpts.format, "format", "f", dmarcOpts.format, "Output format: table or json")
+	dmarcReportCmd.Flags().StringSliceVar(&dmarcOpts.authorized, "authorized", dmarcOpts.authorized, "Authorized sending address or CIDR prefix, can repeat")
+}
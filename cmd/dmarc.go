@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var dmarcCmd = &cobra.Command{
+	Use:     "dmarc",
+	Short:   "DMARC report commands",
+	GroupID: toolsGroup,
+}
+
+func init() {
+	rootCmd.AddCommand(dmarcCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+)
+
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create DNS record",
+	Run: func(cmd *cobra.Command, args []string) {
+		dnsOpts.MustHaveRecord()
+		api := dnsOpts.MustConnectForUpdate(cmd.Context())
+
+		rec := dns.NewRecord(dnsOpts.recordName, dns.RecordType(dnsOpts.recordType), createOpts.content)
+		cobra.CheckErr(api.CreateRecord(cmd.Context(), rec))
+
+		cmd.Printf("created %s %s :: %s\n", rec.Type(), rec.Name(), rec.Content())
+	},
+}
+
+var createOpts = dnsRecordOpts{}
+
+func init() {
+	dnsCmd.AddCommand(createCmd)
+
+	createCmd.Flags().StringVar(&createOpts.content, "content", "", "Record Content")
+	_ = createCmd.MarkFlagRequired("content")
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/ddns"
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+	"github.com/tempusbreve/cloud-init-helper/internal/metadata"
+	"github.com/tempusbreve/cloud-init-helper/internal/systemd"
+)
+
+var ddnsCmd = &cobra.Command{
+	Use:   "ddns",
+	Short: "Keep a DNS record pointed at this instance's public address",
+	Long: `Keep a DNS record pointed at this instance's public address.
+
+The address is read from the cloud's instance metadata (--source metadata,
+see --cloud) or from a plain text probe URL (--source url), and the record
+is only updated when the address changes. Use --once to run a single
+check, e.g. from a boot script.
+
+With --systemd-unit the command prints a systemd unit that runs itself;
+--install-unit writes the unit, and an environment file holding the DNS
+provider configuration, instead.
+
+Examples:
+  cloud-init-helper dns ddns --name host.example.com
+  cloud-init-helper dns ddns --name host.example.com --type AAAA --interval 1m
+  cloud-init-helper dns ddns --name host.example.com --install-unit`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rtype := dns.RecordType(strings.ToUpper(ddnsOpts.recordType))
+		if rtype != dns.RecordTypeA && rtype != dns.RecordTypeAAAA {
+			cobra.CheckErr(fmt.Errorf("invalid record type %q: expected A or AAAA", ddnsOpts.recordType))
+		}
+
+		if ddnsOpts.interval <= 0 {
+			cobra.CheckErr(fmt.Errorf("invalid interval %s: must be positive", ddnsOpts.interval))
+		}
+
+		if ddnsOpts.printUnit || ddnsOpts.installUnit {
+			cobra.CheckErr(ddnsUnit(cmd, rtype))
+			return
+		}
+
+		source, err := ddnsSource(rtype)
+		cobra.CheckErr(err)
+
+		updater := ddns.NewUpdater(
+			ddns.WithAPI(dnsOpts.MustConnectForUpdate(cmd.Context())),
+			ddns.WithName(ddnsOpts.name),
+			ddns.WithRecordType(rtype),
+			ddns.WithSource(source),
+			ddns.WithInterval(ddnsOpts.interval),
+		)
+
+		if ddnsOpts.once {
+			_, err = updater.Check(cmd.Context())
+			cobra.CheckErr(err)
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err = updater.Run(ctx); err != nil && ctx.Err() == nil {
+			cobra.CheckErr(err)
+		}
+	},
+}
+
+var ddnsOpts = ddnsOptions{
+	recordType: "A",
+	source:     "metadata",
+	probeURL:   "https://checkip.amazonaws.com",
+	interval:   5 * time.Minute,
+	unitName:   "cloud-init-helper-ddns",
+	envFile:    "/etc/cloud-init-helper/ddns.env",
+}
+
+type ddnsOptions struct {
+	name        string
+	recordType  string
+	source      string
+	probeURL    string
+	interval    time.Duration
+	once        bool
+	printUnit   bool
+	installUnit bool
+	unitName    string
+	envFile     string
+}
+
+func ddnsSource(rtype dns.RecordType) (ddns.AddressFunc, error) {
+	switch ddnsOpts.source {
+	case "metadata", "imds":
+		p, err := metadataOpts.Provider()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(ctx context.Context) (string, error) {
+			info, err := providerAddresses(ctx, p)
+			if err != nil {
+				return "", err
+			}
+
+			addr := info.PublicIPv4
+			if rtype == dns.RecordTypeAAAA {
+				addr = info.IPv6
+			}
+			if addr == "" {
+				return "", fmt.Errorf("no %s address for %s: %w", rtype, p.Name(), metadata.ErrNotFound)
+			}
+			return addr, nil
+		}, nil
+	case "url":
+		return ddns.ProbeURL(&http.Client{Timeout: 10 * time.Second}, ddnsOpts.probeURL), nil
+	default:
+		return nil, fmt.Errorf("invalid address source %q: expected metadata or url", ddnsOpts.source)
+	}
+}
+
+// ddnsUnit renders a unit that runs this command with the current options.
+// DNS provider configuration goes into the environment file, not the unit,
+// so credentials never end up in a world-readable file.
+func ddnsUnit(cmd *cobra.Command, rtype dns.RecordType) error {
+	// The source is checked but not resolved: the unit may be rendered on
+	// a host where the cloud cannot be detected.
+	switch ddnsOpts.source {
+	case "metadata", "imds", "url":
+	default:
+		return fmt.Errorf("invalid address source %q: expected metadata or url", ddnsOpts.source)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating executable: %w", err)
+	}
+
+	execStart := []string{exe, "dns", "ddns",
+		"--name", ddnsOpts.name,
+		"--type", string(rtype),
+		"--source", ddnsOpts.source,
+		"--interval", ddnsOpts.interval.String(),
+	}
+	if ddnsOpts.source == "url" {
+		execStart = append(execStart, "--probe-url", ddnsOpts.probeURL)
+	} else if metadataOpts.cloud != "auto" {
+		execStart = append(execStart, "--cloud", metadataOpts.cloud)
+	}
+
+	unit := systemd.Unit{
+		Name:            ddnsOpts.unitName,
+		Description:     "Dynamic DNS for " + ddnsOpts.name,
+		ExecStart:       execStart,
+		EnvironmentFile: ddnsOpts.envFile,
+		RestartSec:      30,
+	}
+
+	if !ddnsOpts.installUnit {
+		return unit.Render(cmd.OutOrStdout())
+	}
+
+	env := map[string]string{"DNS_PROVIDER": dnsOpts.provider}
+	for k, v := range dnsOpts.Config() {
+		env[strings.ToUpper(strings.ReplaceAll(k, "-", "_"))] = v
+	}
+
+	if err = systemd.WriteEnvironmentFile(ddnsOpts.envFile, env); err != nil {
+		return err
+	}
+
+	path, err := unit.Install("")
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("installed %s; enable with: systemctl enable --now %s\n", path, unit.FileName())
+	return nil
+}
+
+func init() {
+	dnsCmd.AddCommand(ddnsCmd)
+
+	flags := ddnsCmd.Flags()
+
+	flags.StringVar(&ddnsOpts.name, "name", ddnsOpts.name, "DNS name to keep updated")
+	_ = ddnsCmd.MarkFlagRequired("name")
+	flags.StringVar(&ddnsOpts.recordType, "type", ddnsOpts.recordType, "Record type to update: A or AAAA")
+	flags.StringVar(&ddnsOpts.source, "source", ddnsOpts.source, "Address source: metadata or url")
+	flags.StringVar(&ddnsOpts.probeURL, "probe-url", ddnsOpts.probeURL, "URL returning the public address as plain text, for --source url")
+	flags.DurationVar(&ddnsOpts.interval, "interval", ddnsOpts.interval, "How often to check the address")
+	flags.BoolVar(&ddnsOpts.once, "once", ddnsOpts.once, "Check and update once, then exit")
+	flags.BoolVar(&ddnsOpts.printUnit, "systemd-unit", ddnsOpts.printUnit, "Print a systemd unit running this command and exit")
+	flags.BoolVar(&ddnsOpts.installUnit, "install-unit", ddnsOpts.installUnit, "Install the systemd unit and its environment file and exit")
+	flags.StringVar(&ddnsOpts.unitName, "unit-name", ddnsOpts.unitName, "Name of the systemd unit")
+	flags.StringVar(&ddnsOpts.envFile, "env-file", ddnsOpts.envFile, "Environment file holding the DNS provider configuration")
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete DNS record",
+	Long: `Delete DNS records.
+
+Records are selected by --id, or by --record-name and --record-type,
+optionally narrowed with --content.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		api := dnsOpts.MustConnectForUpdate(cmd.Context())
+
+		if deleteOpts.id != "" {
+			cobra.CheckErr(api.DeleteRecord(cmd.Context(), deleteOpts.id))
+			cmd.Printf("deleted %s\n", deleteOpts.id)
+			return
+		}
+
+		dnsOpts.MustHaveRecord()
+
+		records, err := api.GetRecords(cmd.Context(), dnsOpts.recordName, dnsOpts.recordType)
+		cobra.CheckErr(err)
+
+		for _, rec := range records {
+			if deleteOpts.content != "" && rec.Content() != deleteOpts.content {
+				continue
+			}
+
+			cobra.CheckErr(api.DeleteRecord(cmd.Context(), rec.ID()))
+			cmd.Printf("deleted %v: %s %s :: %s\n", rec.ID(), rec.Type(), rec.Name(), rec.Content())
+		}
+	},
+}
+
+var deleteOpts = dnsRecordOpts{}
+
+func init() {
+	dnsCmd.AddCommand(deleteCmd)
+
+	deleteCmd.Flags().StringVar(&deleteOpts.id, "id", "", "Record ID")
+	deleteCmd.Flags().StringVar(&deleteOpts.content, "content", "", "Only delete Records with this Content")
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+)
+
+var updateDNSCmd = &cobra.Command{
+	Use:     "update-dns",
+	Aliases: []string{"update"},
+	Short:   "Update DNS Records for Maddy",
+	Long: `Update the MX, SPF, DKIM, DMARC and MTA-STS records of the mail domains.
+
+Every domain shares the same MX hosts and gets the DKIM key maddy generated
+for it in /var/lib/maddy/dkim_keys. With several domains, those outside the
+configured --zone-name are updated in the closest enclosing zone the
+provider can see.
+
+MX hosts take an optional priority, e.g. -x mx1.example.com:10 -x
+mx2.example.com:20, and must not be CNAMEs. With --instance-mx-host the
+A/AAAA records of this instance's MX host name are checked against the
+addresses in the instance metadata, or published with --create-mx-address.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if mxAddressOpts.create && mxAddressOpts.host == "" {
+			cobra.CheckErr(fmt.Errorf("--create-mx-address needs --instance-mx-host"))
+		}
+
+		domains, err := mailOpts.Domains()
+		cobra.CheckErr(err)
+
+		mxHosts, err := mailOpts.MXHosts()
+		cobra.CheckErr(err)
+
+		// Connect to and verify every zone before changing any of them.
+		apis := map[string]dns.API{}
+		for _, domain := range domains {
+			if len(domains) == 1 {
+				apis[domain] = dnsOpts.MustConnectForUpdate(cmd.Context())
+			} else {
+				apis[domain] = dnsOpts.MustPreflight(cmd.Context(), dnsOpts.MustConnectZone(cmd.Context(), domain))
+			}
+		}
+
+		if mxAddressOpts.host != "" {
+			cobra.CheckErr(mxAddressOpts.apply(cmd, dnsOpts.MustConnectForUpdate(cmd.Context())))
+		}
+
+		for _, domain := range domains {
+			mc := dns.NewMailConfig(dns.WithAPI(apis[domain]))
+
+			options := dns.UpdateMailRecordsParams{
+				Domain:      domain,
+				Postmaster:  mailOpts.postmaster,
+				DKIM:        mailOpts.dkim,
+				MXHosts:     mxHosts,
+				CAA:         mailOpts.CAA(),
+				ClientHost:  mailOpts.clientHost,
+				Destructive: destructive,
+			}
+
+			if err := mc.UpdateAllMailRecords(cmd.Context(), options); err != nil {
+				cobra.CheckErr(fmt.Errorf("%s: %w", domain, err))
+			}
+			cmd.Printf("updated mail records for %s\n", domain)
+		}
+	},
+}
+
+var destructive bool
+
+var mxAddressOpts mxAddressOptions
+
+type mxAddressOptions struct {
+	host   string
+	create bool
+}
+
+// apply creates or verifies the A/AAAA records of this instance's MX host
+// name from the addresses reported by the instance metadata.
+func (o mxAddressOptions) apply(cmd *cobra.Command, api dns.API) error {
+	addrs, err := instanceAddresses(cmd, "public", true)
+	if err != nil {
+		return err
+	}
+
+	for rtype, values := range map[dns.RecordType][]string{
+		dns.RecordTypeA:    addrs.ipv4,
+		dns.RecordTypeAAAA: addrs.ipv6,
+	} {
+		if len(values) == 0 {
+			continue
+		}
+
+		if o.create {
+			if err := dns.SetRecords(cmd.Context(), api, o.host, rtype, values...); err != nil {
+				return err
+			}
+			cmd.Printf("%s %s :: %v\n", rtype, o.host, values)
+			continue
+		}
+
+		if err := dns.VerifyRecords(cmd.Context(), api, o.host, rtype, values...); err != nil {
+			return fmt.Errorf("%w (use --create-mx-address to publish them)", err)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	dnsMaddyCmd.AddCommand(updateDNSCmd)
+
+	updateDNSCmd.Flags().BoolVarP(&destructive, "destructive", "f", destructive, "Cause conflicting DNS records to be deleted")
+	updateDNSCmd.Flags().StringVar(&mxAddressOpts.host, "instance-mx-host", mxAddressOpts.host, "MX host name of this instance, verifies its A/AAAA records against the instance metadata addresses")
+	updateDNSCmd.Flags().BoolVar(&mxAddressOpts.create, "create-mx-address", mxAddressOpts.create, "Publish the A/AAAA records of --instance-mx-host instead of verifying them")
+}
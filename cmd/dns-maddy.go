@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+	"github.com/tempusbreve/cloud-init-helper/internal/maddy"
+)
+
+var dnsMaddyCmd = &cobra.Command{
+	Use:   "maddy",
+	Short: "DNS related commands for Maddy configuration",
+}
+
+func init() {
+	dnsCmd.AddCommand(dnsMaddyCmd)
+
+	const (
+		mailDomainKey = "mail-domain"
+		postmasterKey = "postmaster"
+		dkimKey       = "dkim"
+		mxHostKey     = "mx-host"
+	)
+
+	dnsMaddyCmd.PersistentFlags().StringSliceVarP(&mailOpts.domains, mailDomainKey, "m", mailOpts.domains, "Mail Domain, can repeat")
+	dnsMaddyCmd.PersistentFlags().StringVar(&mailOpts.maddyConfig, "maddy-config", mailOpts.maddyConfig, "Read the mail domains from $(primary_domain) and $(local_domains) of this maddy.conf, e.g. /etc/maddy/maddy.conf")
+	dnsMaddyCmd.MarkFlagsOneRequired(mailDomainKey, "maddy-config")
+	dnsMaddyCmd.MarkFlagsMutuallyExclusive(mailDomainKey, "maddy-config")
+
+	dnsMaddyCmd.PersistentFlags().StringVarP(&mailOpts.postmaster, postmasterKey, "p", mailOpts.postmaster, "Mail Domain Postmaster email address")
+	_ = dnsMaddyCmd.MarkPersistentFlagRequired(postmasterKey)
+
+	dnsMaddyCmd.PersistentFlags().StringVarP(&mailOpts.dkim, dkimKey, "k", mailOpts.dkim, "DKIM TXT record value, if not provided it will be discovered in the maddy config")
+
+	dnsMaddyCmd.PersistentFlags().StringSliceVarP(&mailOpts.mxHosts, mxHostKey, "x", mailOpts.mxHosts, "MX host as host or host:priority (default priority 10), can repeat")
+	_ = dnsMaddyCmd.MarkPersistentFlagRequired(mxHostKey)
+
+	dnsMaddyCmd.PersistentFlags().StringVar(&mailOpts.clientHost, "client-host", mailOpts.clientHost, "Mail host for RFC 6186 SRV records and autoconfig/autodiscover names, enables them")
+
+	dnsMaddyCmd.PersistentFlags().StringSliceVar(&mailOpts.caaIssuers, "caa-issuer", mailOpts.caaIssuers, "CA allowed to issue certificates (CAA issue), enables CAA records, can repeat")
+	dnsMaddyCmd.PersistentFlags().StringSliceVar(&mailOpts.caaWildIssuers, "caa-issuewild", mailOpts.caaWildIssuers, "CA allowed to issue wildcard certificates (CAA issuewild), use \";\" to forbid, can repeat")
+	dnsMaddyCmd.PersistentFlags().StringVar(&mailOpts.caaAccountURI, "caa-account-uri", mailOpts.caaAccountURI, "Restrict CAA issuance to this ACME account URI")
+	dnsMaddyCmd.PersistentFlags().StringSliceVar(&mailOpts.caaValidationMethods, "caa-validation-methods", mailOpts.caaValidationMethods, "Restrict CAA issuance to these ACME validation methods, e.g. dns-01")
+}
+
+var mailOpts mailOptions
+
+type mailOptions struct {
+	domains     []string
+	maddyConfig string
+
+	postmaster string
+	dkim       string
+	mxHosts    []string
+	clientHost string
+
+	caaIssuers           []string
+	caaWildIssuers       []string
+	caaAccountURI        string
+	caaValidationMethods []string
+}
+
+// Domains returns the mail domains to configure, the first one being the
+// primary domain.
+func (o mailOptions) Domains() ([]string, error) {
+	domains := o.domains
+	if o.maddyConfig != "" {
+		var err error
+		if domains, err = maddy.ReadDomains(o.maddyConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(domains) > 1 && o.dkim != "" {
+		return nil, errors.New("--dkim can only be used with a single mail domain")
+	}
+
+	return domains, nil
+}
+
+func (o mailOptions) MXHosts() (map[string]int, error) {
+	return dns.ParseMXHosts(o.mxHosts)
+}
+
+func (o mailOptions) CAA() *dns.CAAParams {
+	if len(o.caaIssuers)+len(o.caaWildIssuers) == 0 {
+		return nil
+	}
+
+	return &dns.CAAParams{
+		Issuers:           o.caaIssuers,
+		WildcardIssuers:   o.caaWildIssuers,
+		AccountURI:        o.caaAccountURI,
+		ValidationMethods: o.caaValidationMethods,
+	}
+}
@@ -2,36 +2,35 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
-
-	"github.com/tempusbreve/cloud-init-helper/internal/dns"
 )
 
 var readCmd = &cobra.Command{
 	Use:   "read",
 	Short: "Read DNS record",
 	Run: func(cmd *cobra.Command, args []string) {
-		var api dns.API = dns.NewCloudFlareDNS(
-			dns.WithCFToken(cfOpts.token),
-			dns.WithCFZoneName(cfOpts.zoneName),
-		)
+		api := dnsOpts.MustConnect()
 
-		records, err := api.GetRecords(cmd.Context(), cfOpts.recordName, cfOpts.recordType)
+		records, err := api.GetRecords(cmd.Context(), dnsOpts.recordName, dnsOpts.recordType)
 		cobra.CheckErr(err)
 
 		for _, rec := range records {
-			cmd.Printf("%s: %s %s :: %s\n", rec.ID(), rec.Type(), rec.Name(), rec.Content())
+			if readOpts.content != "" && rec.Content() != readOpts.content {
+				continue
+			}
+			cmd.Printf("%v: %s %s :: %s\n", rec.ID(), rec.Type(), rec.Name(), rec.Content())
 		}
 	},
 }
 
-var readOpts = cloudflareReadOpts{}
+var readOpts = dnsRecordOpts{}
 
-type cloudflareReadOpts struct {
+type dnsRecordOpts struct {
+	id      string
 	content string
 }
 
 func init() {
-	cloudflareCmd.AddCommand(readCmd)
+	dnsCmd.AddCommand(readCmd)
 
 	const (
 		readContent = "content"
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+	"github.com/tempusbreve/cloud-init-helper/internal/metadata"
+)
+
+var registerHostCmd = &cobra.Command{
+	Use:   "register-host",
+	Short: "Publish A/AAAA records for this instance",
+	Long: `Publish A/AAAA records for this instance, using the addresses the
+cloud's metadata service reports.
+
+The A record is set to the public IPv4 address (or the local one with
+--ipv4 local), the AAAA record to the instance's IPv6 address when one is
+assigned. Any other A/AAAA records for the name are removed; AAAA records
+are left alone when the instance has no IPv6 address. Aliases are
+published as CNAME records pointing at the name.
+
+Examples:
+  cloud-init-helper dns register-host --name mx1.example.com
+  cloud-init-helper dns register-host --name mx1.example.com --alias mail.example.com`,
+	Run: func(cmd *cobra.Command, args []string) {
+		api := dnsOpts.MustConnectForUpdate(cmd.Context())
+
+		addrs, err := instanceAddresses(cmd, hostOpts.ipv4, hostOpts.ipv6)
+		cobra.CheckErr(err)
+
+		if hostOpts.ipv4 != "none" {
+			cobra.CheckErr(dns.SetRecords(cmd.Context(), api, hostOpts.name, dns.RecordTypeA, addrs.ipv4...))
+			cmd.Printf("A %s :: %v\n", hostOpts.name, addrs.ipv4)
+		}
+
+		// Without an address SetRecords would delete every AAAA record,
+		// including ones published by hand.
+		if hostOpts.ipv6 && len(addrs.ipv6) > 0 {
+			cobra.CheckErr(dns.SetRecords(cmd.Context(), api, hostOpts.name, dns.RecordTypeAAAA, addrs.ipv6...))
+			cmd.Printf("AAAA %s :: %v\n", hostOpts.name, addrs.ipv6)
+		}
+
+		for _, alias := range hostOpts.aliases {
+			cobra.CheckErr(dns.SetRecords(cmd.Context(), api, alias, dns.RecordTypeCNAME, hostOpts.name))
+			cmd.Printf("CNAME %s :: %s\n", alias, hostOpts.name)
+		}
+	},
+}
+
+var deregisterHostCmd = &cobra.Command{
+	Use:   "deregister-host",
+	Short: "Remove the A/AAAA records of this instance",
+	Long: `Remove the A/AAAA records of this instance, and any aliases pointing
+at it.
+
+Only records matching the addresses the metadata service reports for this
+instance are removed, so a successor that already registered the name is
+left alone. Use --all to remove every A/AAAA record for the name.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		api := dnsOpts.MustConnectForUpdate(cmd.Context())
+
+		var addrs hostAddresses
+		if !hostOpts.all {
+			var err error
+			addrs, err = instanceAddresses(cmd, hostOpts.ipv4, hostOpts.ipv6)
+			cobra.CheckErr(err)
+
+			if len(addrs.ipv4)+len(addrs.ipv6) == 0 {
+				cmd.PrintErrln("no instance addresses found; nothing to remove")
+				return
+			}
+		}
+
+		for _, alias := range hostOpts.aliases {
+			cobra.CheckErr(dns.DeleteRecords(cmd.Context(), api, alias, dns.RecordTypeCNAME, hostOpts.name))
+		}
+
+		if hostOpts.all || len(addrs.ipv4) > 0 {
+			cobra.CheckErr(dns.DeleteRecords(cmd.Context(), api, hostOpts.name, dns.RecordTypeA, addrs.ipv4...))
+		}
+
+		if hostOpts.all || len(addrs.ipv6) > 0 {
+			cobra.CheckErr(dns.DeleteRecords(cmd.Context(), api, hostOpts.name, dns.RecordTypeAAAA, addrs.ipv6...))
+		}
+
+		cmd.Printf("deregistered %s\n", hostOpts.name)
+	},
+}
+
+var hostOpts = hostOptions{
+	ipv4: "public",
+	ipv6: true,
+}
+
+type hostOptions struct {
+	name    string
+	aliases []string
+	ipv4    string
+	ipv6    bool
+	all     bool
+}
+
+type hostAddresses struct {
+	ipv4 []string
+	ipv6 []string
+}
+
+// instanceAddresses looks up the addresses to publish for this instance.
+// source selects the public or local IPv4 address, or none.
+func instanceAddresses(cmd *cobra.Command, source string, withIPv6 bool) (hostAddresses, error) {
+	var addrs hostAddresses
+
+	switch source {
+	case "public", "local", "none":
+	default:
+		return addrs, fmt.Errorf("invalid ipv4 source %q: expected public, local or none", source)
+	}
+
+	info, err := metadataOpts.Addresses(cmd.Context())
+	if err != nil {
+		return addrs, err
+	}
+
+	ipv4 := map[string]string{"public": info.PublicIPv4, "local": info.LocalIPv4}[source]
+	if source != "none" {
+		if ipv4 == "" {
+			return addrs, fmt.Errorf("getting %s ipv4 address: %w", source, metadata.ErrNotFound)
+		}
+		addrs.ipv4 = append(addrs.ipv4, ipv4)
+	}
+
+	if withIPv6 {
+		if info.IPv6 != "" {
+			addrs.ipv6 = append(addrs.ipv6, info.IPv6)
+		} else {
+			cmd.PrintErrln("no ipv6 address assigned")
+		}
+	}
+
+	return addrs, nil
+}
+
+func init() {
+	dnsCmd.AddCommand(registerHostCmd)
+	dnsCmd.AddCommand(deregisterHostCmd)
+
+	for _, c := range []*cobra.Command{registerHostCmd, deregisterHostCmd} {
+		c.Flags().StringVar(&hostOpts.name, "name", hostOpts.name, "DNS name of this host")
+		_ = c.MarkFlagRequired("name")
+		c.Flags().StringSliceVar(&hostOpts.aliases, "alias", hostOpts.aliases, "CNAME alias pointing at the host name, can repeat")
+		c.Flags().StringVar(&hostOpts.ipv4, "ipv4", hostOpts.ipv4, "IPv4 address to publish: public, local or none")
+		c.Flags().BoolVar(&hostOpts.ipv6, "ipv6", hostOpts.ipv6, "Publish the IPv6 address, if one is assigned")
+	}
+
+	deregisterHostCmd.Flags().BoolVar(&hostOpts.all, "all", hostOpts.all, "Remove all A/AAAA records for the name, not just this instance's")
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+)
+
+var sshfpCmd = &cobra.Command{
+	Use:   "sshfp",
+	Short: "Publish SSHFP records for the host's SSH keys",
+	Long: `Publish SHA-256 SSHFP records for the host keys in /etc/ssh.
+
+The records for --name are replaced, so fingerprints left over from a
+previous instance with the same name are removed. Clients can then verify
+host keys with "ssh -o VerifyHostKeyDNS=yes".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		values, err := dns.SSHFPValues(sshfpOpts.keys)
+		cobra.CheckErr(err)
+
+		if len(values) == 0 {
+			cobra.CheckErr(fmt.Errorf("no supported host keys match %q", sshfpOpts.keys))
+		}
+
+		if sshfpOpts.dryRun {
+			for _, v := range values {
+				cmd.Printf("%s SSHFP %s\n", sshfpOpts.name, v)
+			}
+			return
+		}
+
+		cobra.CheckErr(dns.SetRecords(cmd.Context(), dnsOpts.MustConnectForUpdate(cmd.Context()), sshfpOpts.name, dns.RecordTypeSSHFP, values...))
+		cmd.Printf("published %d SSHFP records for %s\n", len(values), sshfpOpts.name)
+	},
+}
+
+var sshfpOpts = sshfpOptions{keys: dns.DefaultSSHHostKeyPattern}
+
+type sshfpOptions struct {
+	name   string
+	keys   string
+	dryRun bool
+}
+
+func init() {
+	dnsCmd.AddCommand(sshfpCmd)
+
+	sshfpCmd.Flags().StringVar(&sshfpOpts.name, "name", sshfpOpts.name, "DNS name of the host")
+	_ = sshfpCmd.MarkFlagRequired("name")
+	sshfpCmd.Flags().StringVar(&sshfpOpts.keys, "keys", sshfpOpts.keys, "Glob matching the host public key files")
+	sshfpCmd.Flags().BoolVar(&sshfpOpts.dryRun, "dry-run", sshfpOpts.dryRun, "Print the records instead of publishing them")
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+	"github.com/tempusbreve/cloud-init-helper/internal/maddy"
+)
+
+var tlsaCmd = &cobra.Command{
+	Use:   "tlsa",
+	Short: "DANE TLSA records for the maddy certificate",
+	Long: `Publish DANE TLSA records (3 1 1) for maddy's SMTP certificate.
+
+The certificate defaults to /etc/maddy/certs/<host>/fullchain.pem, or to
+$RENEWED_LINEAGE/fullchain.pem when run as a certbot deploy hook.
+
+A key rollover takes two steps, so that the new key is in DNS before it
+is served:
+
+  # once the next key exists (e.g. a pre-generated key used with --csr),
+  # publish its hash next to the current one and wait at least two TTLs
+  cloud-init-helper dns tlsa prepare --host mx1.example.com --next /etc/maddy/next.key
+
+  # after the renewal is deployed, e.g. from certbot --deploy-hook,
+  # publish only the current hash, dropping the old one
+  cloud-init-helper dns tlsa publish --host mx1.example.com`,
+}
+
+var tlsaShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the TLSA records for the certificate",
+	Run: func(cmd *cobra.Command, args []string) {
+		current, next := tlsaValues()
+		name := dns.TLSAName(tlsaOpts.host, tlsaOpts.port)
+
+		cmd.Printf("%s TLSA %s\n", name, current)
+		if next != "" {
+			cmd.Printf("%s TLSA %s\n", name, next)
+		}
+	},
+}
+
+var tlsaPublishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Publish the TLSA records, removing any others",
+	Run:   publishTLSA,
+}
+
+var tlsaPrepareCmd = &cobra.Command{
+	Use:   "prepare",
+	Short: "Publish the next key's TLSA record alongside the current ones",
+	Run: func(cmd *cobra.Command, args []string) {
+		if tlsaOpts.next == "" {
+			cobra.CheckErr(fmt.Errorf("--next is required"))
+		}
+
+		_, next := tlsaValues()
+		name := dns.TLSAName(tlsaOpts.host, tlsaOpts.port)
+
+		cobra.CheckErr(dns.AddTLSA(cmd.Context(), dnsOpts.MustConnectForUpdate(cmd.Context()), name, next))
+		cmd.Printf("added %s TLSA %s\n", name, next)
+	},
+}
+
+var tlsaOpts = tlsaOptions{port: 25}
+
+type tlsaOptions struct {
+	host string
+	port int
+	cert string
+	next string
+}
+
+func (o tlsaOptions) certFile() string {
+	if o.cert != "" {
+		return o.cert
+	}
+
+	if lineage := os.Getenv("RENEWED_LINEAGE"); lineage != "" {
+		return filepath.Join(lineage, "fullchain.pem")
+	}
+
+	return maddy.ConfigParameters{Hostname: o.host}.CertificateFile()
+}
+
+func publishTLSA(cmd *cobra.Command, args []string) {
+	current, next := tlsaValues()
+	name := dns.TLSAName(tlsaOpts.host, tlsaOpts.port)
+
+	values := []string{current}
+	if next != "" {
+		values = append(values, next)
+	}
+
+	cobra.CheckErr(dns.SetTLSA(cmd.Context(), dnsOpts.MustConnectForUpdate(cmd.Context()), name, values...))
+	cmd.Printf("published %s TLSA %v\n", name, values)
+}
+
+// tlsaValues returns the record content for the current certificate and,
+// when --next is given, for the next key.
+func tlsaValues() (string, string) {
+	current := mustTLSAValue(tlsaOpts.certFile())
+
+	var next string
+	if tlsaOpts.next != "" {
+		next = mustTLSAValue(tlsaOpts.next)
+	}
+
+	return current, next
+}
+
+func mustTLSAValue(path string) string {
+	data, err := os.ReadFile(path)
+	cobra.CheckErr(err)
+
+	value, err := dns.TLSAValueFromPEM(data)
+	if err != nil {
+		cobra.CheckErr(fmt.Errorf("%s: %w", path, err))
+	}
+
+	return value
+}
+
+func init() {
+	dnsCmd.AddCommand(tlsaCmd)
+	tlsaCmd.AddCommand(tlsaShowCmd, tlsaPublishCmd, tlsaPrepareCmd)
+
+	pf := tlsaCmd.PersistentFlags()
+	pf.StringVar(&tlsaOpts.host, "host", tlsaOpts.host, "MX host name the certificate is served for")
+	_ = tlsaCmd.MarkPersistentFlagRequired("host")
+	pf.IntVar(&tlsaOpts.port, "port", tlsaOpts.port, "TCP port of the TLS service")
+	pf.StringVar(&tlsaOpts.cert, "cert", tlsaOpts.cert, "Certificate file, defaults to maddy's certificate for the host")
+	pf.StringVar(&tlsaOpts.next, "next", tlsaOpts.next, "Certificate, CSR or key file of the next key")
+}
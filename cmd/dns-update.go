@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update DNS record",
+	Long: `Update the content of a DNS record.
+
+The record is selected by --id, or by --record-name and --record-type when
+exactly one record matches.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		api := dnsOpts.MustConnectForUpdate(cmd.Context())
+
+		var existing dns.Record
+		if updateOpts.id != "" {
+			rec, err := api.GetRecord(cmd.Context(), updateOpts.id)
+			cobra.CheckErr(err)
+			existing = rec
+		} else {
+			dnsOpts.MustHaveRecord()
+
+			records, err := api.GetRecords(cmd.Context(), dnsOpts.recordName, dnsOpts.recordType)
+			cobra.CheckErr(err)
+
+			if len(records) != 1 {
+				cobra.CheckErr(fmt.Errorf("expected exactly one %s record for %q, found %d", dnsOpts.recordType, dnsOpts.recordName, len(records)))
+			}
+			existing = records[0]
+		}
+
+		rec := dns.NewRecordWithID(existing.ID(), existing.Name(), existing.Type(), updateOpts.content)
+		cobra.CheckErr(api.UpdateRecord(cmd.Context(), rec))
+
+		cmd.Printf("updated %v: %s %s :: %s\n", rec.ID(), rec.Type(), rec.Name(), rec.Content())
+	},
+}
+
+var updateOpts = dnsRecordOpts{}
+
+func init() {
+	dnsCmd.AddCommand(updateCmd)
+
+	updateCmd.Flags().StringVar(&updateOpts.id, "id", "", "Record ID")
+	updateCmd.Flags().StringVar(&updateOpts.content, "content", "", "New Record Content")
+	_ = updateCmd.MarkFlagRequired("content")
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+)
+
+var verifyTokenCmd = &cobra.Command{
+	Use:     "verify-token",
+	Aliases: []string{"verify"},
+	Short:   "Verify the provider credentials and zone permissions",
+	Long: `Verify the provider credentials without changing anything.
+
+For Cloudflare the token must be active and have the Zone.DNS Edit
+permission on the zone. Commands that change records run the same check
+first, unless --skip-preflight is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		api := dnsOpts.MustConnect()
+
+		cf, ok := api.(*dns.CloudFlareDNS)
+		if !ok {
+			cobra.CheckErr(dns.Verify(cmd.Context(), api))
+			cmd.Printf("%s credentials are valid\n", dnsOpts.provider)
+			return
+		}
+
+		status, err := cf.VerifyToken(cmd.Context())
+		if status.ID != "" {
+			cmd.Printf("token:    %s (%s)\n", status.ID, status.Status)
+			if !status.ExpiresOn.IsZero() {
+				cmd.Printf("expires:  %s\n", status.ExpiresOn)
+			}
+		}
+		cobra.CheckErr(err)
+
+		cmd.Printf("zone:     %s (%s)\n", status.ZoneName, status.ZoneID)
+		cmd.Printf("dns edit: %t\n", status.DNSEdit)
+
+		if !status.DNSEdit {
+			cobra.CheckErr(errors.New("token cannot edit DNS records on the zone"))
+		}
+	},
+}
+
+func init() {
+	dnsCmd.AddCommand(verifyTokenCmd)
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+)
+
+var dnsCmd = &cobra.Command{
+	Use:     "dns",
+	Aliases: []string{"cloudflare", "cf"},
+	Short:   "DNS helper commands",
+	GroupID: toolsGroup,
+}
+
+var dnsOpts = dnsOptions{
+	provider: "cloudflare",
+	config:   map[string]*string{},
+}
+
+// dnsFlagShorthands keeps the short flags the cloudflare command always had.
+var dnsFlagShorthands = map[string]string{
+	"token":     "t",
+	"zone-name": "z",
+	"zone-id":   "i",
+}
+
+func init() {
+	rootCmd.AddCommand(dnsCmd)
+
+	const (
+		dnsProvider   = "dns-provider"
+		dnsRecordType = "record-type"
+		dnsRecordName = "record-name"
+	)
+
+	flags := dnsCmd.PersistentFlags()
+
+	var names []string
+	for _, p := range dns.Providers() {
+		names = append(names, p.Name)
+
+		for _, f := range p.Fields {
+			if _, ok := dnsOpts.config[f.Name]; ok {
+				// Fields shared by several providers get one flag.
+				flags.Lookup(f.Name).Usage += "; " + f.Description
+				continue
+			}
+
+			v := new(string)
+			dnsOpts.config[f.Name] = v
+			flags.StringVarP(v, f.Name, dnsFlagShorthands[f.Name], "", f.Description)
+		}
+	}
+
+	flags.StringVarP(&dnsOpts.provider, dnsProvider, "P", dnsOpts.provider, "DNS provider ("+strings.Join(names, ", ")+")")
+	flags.StringVarP(&dnsOpts.recordType, dnsRecordType, "y", dnsOpts.recordType, "Record Type (MX, A, TXT, etc)")
+	flags.StringVarP(&dnsOpts.recordName, dnsRecordName, "n", dnsOpts.recordName, "Record Name")
+	flags.BoolVar(&dnsOpts.skipPreflight, "skip-preflight", dnsOpts.skipPreflight, "Do not verify the provider credentials before changing records")
+}
+
+type dnsOptions struct {
+	provider      string
+	config        map[string]*string
+	recordType    string
+	recordName    string
+	skipPreflight bool
+}
+
+func (o dnsOptions) Config() dns.Config {
+	cfg := dns.Config{}
+	for k, v := range o.config {
+		if *v != "" {
+			cfg[k] = *v
+		}
+	}
+	return cfg
+}
+
+func (o dnsOptions) MustConnect() dns.API {
+	api, err := dns.New(o.provider, o.Config())
+	cobra.CheckErr(err)
+	return api
+}
+
+// MustConnectForUpdate connects like MustConnect and verifies that the
+// credentials may change records, so that commands fail before their first
+// change rather than partway through.
+func (o dnsOptions) MustConnectForUpdate(ctx context.Context) dns.API {
+	return o.MustPreflight(ctx, o.MustConnect())
+}
+
+func (o dnsOptions) MustPreflight(ctx context.Context, api dns.API) dns.API {
+	if !o.skipPreflight {
+		if err := dns.Verify(ctx, api); err != nil {
+			cobra.CheckErr(fmt.Errorf("preflight failed (use --skip-preflight to bypass): %w", err))
+		}
+	}
+	return api
+}
+
+// MustConnectZone connects to the zone holding domain: the configured zone
+// when domain is inside it, otherwise the closest enclosing zone the
+// provider can see.
+func (o dnsOptions) MustConnectZone(ctx context.Context, domain string) dns.API {
+	cfg := o.Config()
+
+	zone := strings.TrimSuffix(strings.ToLower(cfg.Get("zone-name")), ".")
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	if zone != "" && (domain == zone || strings.HasSuffix(domain, "."+zone)) {
+		return o.MustConnect()
+	}
+
+	cfg["zone-name"] = domain
+	delete(cfg, "zone-id")
+
+	api, err := dns.New(o.provider, cfg)
+	cobra.CheckErr(err)
+
+	cfg["zone-name"], err = dns.FindZone(ctx, api, domain)
+	cobra.CheckErr(err)
+
+	api, err = dns.New(o.provider, cfg)
+	cobra.CheckErr(err)
+	return api
+}
+
+func (o dnsOptions) MustHaveRecord() {
+	if o.recordName == "" || o.recordType == "" {
+		cobra.CheckErr(errors.New("both --record-name and --record-type are required"))
+	}
+}
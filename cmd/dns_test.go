@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns/cftest"
+	"github.com/tempusbreve/cloud-init-helper/internal/imds"
+)
+
+const testZoneID = "023e105f4ecef8ad9ca31a8372d0c353"
+
+// execute runs the root command with args and returns what it printed.
+// Flags are reset to their defaults when the test ends.
+func execute(t *testing.T, args ...string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs(args)
+	t.Cleanup(func() {
+		rootCmd.SetArgs(nil)
+		resetFlags(rootCmd)
+	})
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("%v: %v\n%s", args, err, out.String())
+	}
+
+	return out.String()
+}
+
+// resetFlags sets the flags of c and its subcommands back to their
+// defaults. Slice flags append once set, so they are replaced instead.
+func resetFlags(c *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			var values []string
+			if def := strings.Trim(f.DefValue, "[]"); def != "" {
+				values = strings.Split(def, ",")
+			}
+			_ = sv.Replace(values)
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+
+	c.Flags().VisitAll(reset)
+	c.PersistentFlags().VisitAll(reset)
+
+	for _, sub := range c.Commands() {
+		resetFlags(sub)
+	}
+}
+
+func TestDNSCommands(t *testing.T) {
+	server := cftest.NewServer(cftest.WithZone(testZoneID, "example.com"))
+	defer server.Close()
+
+	conn := []string{"--api-url", server.URL, "--token", cftest.DefaultToken, "--zone-name", "example.com"}
+	run := func(args ...string) string { return execute(t, append(args, conn...)...) }
+
+	if out := run("dns", "verify-token"); !strings.Contains(out, "example.com") {
+		t.Errorf("Expected the zone in the token status, got %q", out)
+	}
+
+	run("dns", "create", "-n", "www.example.com", "-y", "A", "--content", "192.0.2.1")
+	run("dns", "create", "-n", "www.example.com", "-y", "A", "--content", "192.0.2.2")
+
+	if out := run("dns", "read", "-n", "www.example.com", "-y", "A", "--content", ""); strings.Count(out, "www.example.com") != 2 {
+		t.Errorf("Expected both records, got %q", out)
+	}
+
+	run("dns", "delete", "-n", "www.example.com", "-y", "A", "--content", "192.0.2.1")
+
+	records := server.Records(testZoneID, "A")
+	if len(records) != 1 || records[0].Content != "192.0.2.2" {
+		t.Errorf("Expected only 192.0.2.2 to remain, got %+v", records)
+	}
+
+	run("dns", "maddy", "update-dns", "-m", "example.com", "-p", "postmaster@example.com",
+		"-k", "v=DKIM1; k=rsa; p=AAAA", "-x", "mx1.example.com:10")
+
+	if mx := server.Records(testZoneID, "MX"); len(mx) != 1 || mx[0].Content != "mx1.example.com" {
+		t.Errorf("Expected the MX record, got %+v", mx)
+	}
+	if txt := server.Records(testZoneID, "TXT"); len(txt) == 0 {
+		t.Error("Expected SPF, DKIM and DMARC records")
+	}
+}
+
+func TestRegisterHost_IPv4Only(t *testing.T) {
+	server := cftest.NewServer(cftest.WithZone(testZoneID, "example.com"))
+	defer server.Close()
+
+	fixture := &imds.Fixture{MetaData: map[string]any{
+		"instance-id": "i-1234567890abcdef0",
+		"public-ipv4": "198.51.100.7",
+		"mac":         "0e:00:00:00:00:01",
+	}}
+	emulator := httptest.NewServer(imds.NewEmulator(fixture))
+	defer emulator.Close()
+	t.Setenv(imds.EndpointEnv, emulator.URL)
+
+	conn := []string{"--api-url", server.URL, "--token", cftest.DefaultToken, "--zone-name", "example.com"}
+	run := func(args ...string) string { return execute(t, append(args, conn...)...) }
+
+	run("dns", "create", "-n", "mx1.example.com", "-y", "AAAA", "--content", "2001:db8::1")
+	run("dns", "register-host", "--name", "mx1.example.com", "--cloud", "aws")
+
+	if a := server.Records(testZoneID, "A"); len(a) != 1 || a[0].Content != "198.51.100.7" {
+		t.Errorf("Expected the public address, got %+v", a)
+	}
+	if aaaa := server.Records(testZoneID, "AAAA"); len(aaaa) != 1 || aaaa[0].Content != "2001:db8::1" {
+		t.Errorf("Expected the existing AAAA record to stay, got %+v", aaaa)
+	}
+}
+
+// The unit can be rendered on a host where the cloud is not detected.
+func TestDDNS_SystemdUnit(t *testing.T) {
+	out := execute(t, "dns", "ddns", "--name", "host.example.com", "--cloud", "auto", "--systemd-unit")
+
+	if !strings.Contains(out, "dns ddns --name host.example.com --type A --source metadata") {
+		t.Errorf("Expected the ddns command in the unit, got %q", out)
+	}
+}
+
+// With --instance-mx-host alone the MX host's address is only verified.
+func TestUpdateDNS_VerifyMXAddress(t *testing.T) {
+	server := cftest.NewServer(cftest.WithZone(testZoneID, "example.com"))
+	defer server.Close()
+
+	fixture := &imds.Fixture{MetaData: map[string]any{
+		"instance-id": "i-1234567890abcdef0",
+		"public-ipv4": "198.51.100.7",
+		"mac":         "0e:00:00:00:00:01",
+	}}
+	emulator := httptest.NewServer(imds.NewEmulator(fixture))
+	defer emulator.Close()
+	t.Setenv(imds.EndpointEnv, emulator.URL)
+
+	conn := []string{"--api-url", server.URL, "--token", cftest.DefaultToken, "--zone-name", "example.com"}
+	run := func(args ...string) string { return execute(t, append(args, conn...)...) }
+
+	run("dns", "create", "-n", "mx1.example.com", "-y", "A", "--content", "198.51.100.7")
+	run("dns", "maddy", "update-dns", "-m", "example.com", "-p", "postmaster@example.com",
+		"-k", "v=DKIM1; k=rsa; p=AAAA", "-x", "mx1.example.com:10",
+		"--instance-mx-host", "mx1.example.com", "--cloud", "aws")
+
+	if a := server.Records(testZoneID, "A"); len(a) != 1 || a[0].Content != "198.51.100.7" {
+		t.Errorf("Expected the A record to be left as it was, got %+v", a)
+	}
+	if mx := server.Records(testZoneID, "MX"); len(mx) != 1 || mx[0].Content != "mx1.example.com" {
+		t.Errorf("Expected the MX record, got %+v", mx)
+	}
+}
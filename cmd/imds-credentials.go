@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/imds"
+)
+
+var imdsCredentialsCmd = &cobra.Command{
+	Use:   "credentials [role]",
+	Short: "Print the instance's IAM role credentials",
+	Long: `Fetch the temporary credentials of the instance's IAM role from IMDS and
+print them in a format other tools read. Without a role argument the role
+attached to the instance is used.
+
+Formats:
+  env       shell export statements
+  process   a credential_process document for the AWS CLI and SDKs
+  profile   a profile for ~/.aws/credentials, named with --profile
+
+Examples:
+  eval "$(cloud-init-helper imds credentials)"
+  cloud-init-helper imds credentials -f profile --profile mx1 >> ~/.aws/credentials
+
+  # ~/.aws/config
+  [profile mx1]
+  credential_process = cloud-init-helper imds credentials -f process`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var role string
+		if len(args) > 0 {
+			role = args[0]
+		}
+
+		creds, err := imdsOpts.NewClient().GetIAMCredentials(ctx, role)
+		if err != nil {
+			return fmt.Errorf("getting iam credentials: %w", err)
+		}
+
+		switch imdsCredentialsOpts.format {
+		case "env":
+			return writeCredentialsEnv(cmd.OutOrStdout(), creds)
+		case "process":
+			return writeCredentialsProcess(cmd.OutOrStdout(), creds)
+		case "profile":
+			return writeCredentialsProfile(cmd.OutOrStdout(), imdsCredentialsOpts.profile, creds)
+		default:
+			return fmt.Errorf("invalid format %q: expected env, process or profile", imdsCredentialsOpts.format)
+		}
+	},
+}
+
+var imdsCredentialsOpts = imdsCredentialsOptions{
+	format:  "env",
+	profile: "default",
+}
+
+type imdsCredentialsOptions struct {
+	format  string
+	profile string
+}
+
+func writeCredentialsEnv(w io.Writer, creds *imds.Credentials) error {
+	for _, kv := range [][2]string{
+		{"AWS_ACCESS_KEY_ID", creds.AccessKeyID},
+		{"AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", creds.Token},
+		{"AWS_CREDENTIAL_EXPIRATION", creds.Expiration.UTC().Format(time.RFC3339)},
+	} {
+		if _, err := fmt.Fprintf(w, "export %s=%s\n", kv[0], shellQuote(kv[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCredentialsProcess writes the document the AWS CLI expects from a
+// credential_process command.
+func writeCredentialsProcess(w io.Writer, creds *imds.Credentials) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Version         int    `json:"Version"`
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string `json:"SecretAccessKey"`
+		SessionToken    string `json:"SessionToken"`
+		Expiration      string `json:"Expiration"`
+	}{
+		Version:         1,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.Token,
+		Expiration:      creds.Expiration.UTC().Format(time.RFC3339),
+	})
+}
+
+func writeCredentialsProfile(w io.Writer, profile string, creds *imds.Credentials) error {
+	_, err := fmt.Fprintf(w, "[%s]\n# expires %s\naws_access_key_id = %s\naws_secret_access_key = %s\naws_session_token = %s\n",
+		profile, creds.Expiration.UTC().Format(time.RFC3339), creds.AccessKeyID, creds.SecretAccessKey, creds.Token)
+	return err
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func init() {
+	imdsCmd.AddCommand(imdsCredentialsCmd)
+
+	imdsCredentialsCmd.Flags().StringVarP(&imdsCredentialsOpts.format, "format", "f", imdsCredentialsOpts.format, "Output format: env, process or profile")
+	imdsCredentialsCmd.Flags().StringVar(&imdsCredentialsOpts.profile, "profile", imdsCredentialsOpts.profile, "Profile name for -f profile")
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/imds"
+)
+
+var imdsDumpCmd = &cobra.Command{
+	Use:   "dump [prefix]",
+	Short: "Dump the metadata tree as one JSON or YAML document",
+	Long: `Walk the instance metadata below prefix and print it as one nested
+document. Keys are sorted and JSON values are decoded, so dumps of two
+instances can be diffed.
+
+IAM role and identity credentials (iam/security-credentials/ and
+identity-credentials/) are not read and show as "<redacted>", so a dump
+can be shared; --include-credentials prints them.
+
+Examples:
+  cloud-init-helper imds dump > instance.json
+  cloud-init-helper imds dump network/interfaces -f yaml
+  cloud-init-helper imds dump iam --include-credentials`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), imdsDumpOpts.timeout)
+		defer cancel()
+
+		var prefix string
+		if len(args) > 0 {
+			prefix = args[0]
+		}
+
+		tree, err := imdsOpts.NewClient().Dump(ctx, prefix, imds.WithCredentials(imdsDumpOpts.includeCredentials))
+		if err != nil {
+			return fmt.Errorf("dumping metadata: %w", err)
+		}
+
+		switch imdsDumpOpts.format {
+		case "json":
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(tree)
+		case "yaml":
+			enc := yaml.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent(2)
+			if err = enc.Encode(tree); err != nil {
+				return err
+			}
+			return enc.Close()
+		default:
+			return fmt.Errorf("invalid format %q: expected json or yaml", imdsDumpOpts.format)
+		}
+	},
+}
+
+var imdsDumpOpts = imdsDumpOptions{
+	format:  "json",
+	timeout: time.Minute,
+}
+
+type imdsDumpOptions struct {
+	format             string
+	timeout            time.Duration
+	includeCredentials bool
+}
+
+func init() {
+	imdsCmd.AddCommand(imdsDumpCmd)
+
+	imdsDumpCmd.Flags().StringVarP(&imdsDumpOpts.format, "format", "f", imdsDumpOpts.format, "Output format: json or yaml")
+	imdsDumpCmd.Flags().DurationVar(&imdsDumpOpts.timeout, "timeout", imdsDumpOpts.timeout, "How long the whole walk may take")
+	imdsDumpCmd.Flags().BoolVar(&imdsDumpOpts.includeCredentials, "include-credentials", imdsDumpOpts.includeCredentials, "Print IAM role and identity credentials instead of redacting them")
+}
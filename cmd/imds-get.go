@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/tempusbreve/cloud-init-helper/internal/imds"
 )
 
 var imdsGetCmd = &cobra.Command{
@@ -31,7 +30,7 @@ Examples:
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		client := imds.NewClient()
+		client := imdsOpts.NewClient()
 
 		if len(args) == 0 {
 			paths, err := client.ListMetadataPaths(ctx, "")
@@ -2,33 +2,121 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/spf13/cobra"
+
 	"github.com/tempusbreve/cloud-init-helper/internal/imds"
 )
 
 var imdsIdentityCmd = &cobra.Command{
 	Use:   "identity",
 	Short: "Get EC2 instance identity document",
-	Long:  `Get the EC2 instance identity document in JSON format.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+	Long: `Get the EC2 instance identity document in JSON format.
 
-		client := imds.NewClient()
+With --signed the document is printed together with its pkcs7 and rsa2048
+signatures, as one JSON object to hand to a service that needs proof of
+the instance's identity. With --verify the rsa2048 signature is checked
+against the AWS certificate bundled for the document's region, and the
+command fails if it does not match. --from verifies or prints a signed
+identity saved earlier instead of asking IMDS.
 
-		document, err := client.GetInstanceIdentityDocument(ctx)
+Examples:
+  cloud-init-helper imds identity --verify
+  cloud-init-helper imds identity --signed > identity.json
+  cloud-init-helper imds identity --verify --from identity.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := imdsIdentityOpts.signedIdentity()
 		if err != nil {
-			return fmt.Errorf("getting instance identity document: %w", err)
+			return err
+		}
+
+		if imdsIdentityOpts.verify {
+			doc, err := id.Verify()
+			if err != nil {
+				return fmt.Errorf("verifying instance identity: %w", err)
+			}
+			cmd.PrintErrf("verified %s in account %s (%s, %s)\n", doc.InstanceID, doc.AccountID, doc.Region, doc.Partition())
+		}
+
+		if imdsIdentityOpts.signed {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(id)
 		}
 
-		fmt.Print(document)
+		fmt.Fprint(cmd.OutOrStdout(), id.Document)
 		return nil
 	},
 }
 
+var imdsIdentityOpts imdsIdentityOptions
+
+type imdsIdentityOptions struct {
+	verify bool
+	signed bool
+	from   string
+}
+
+// signedIdentity reads the signed identity from --from, or from IMDS. The
+// signatures are only fetched when they are needed.
+func (o imdsIdentityOptions) signedIdentity() (*imds.SignedIdentity, error) {
+	if o.from != "" {
+		return readSignedIdentity(o.from)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := imdsOpts.NewClient()
+
+	if o.verify || o.signed {
+		id, err := client.GetSignedIdentity(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("getting signed instance identity: %w", err)
+		}
+		return id, nil
+	}
+
+	document, err := client.GetInstanceIdentityDocument(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting instance identity document: %w", err)
+	}
+
+	return &imds.SignedIdentity{Document: document}, nil
+}
+
+func readSignedIdentity(path string) (*imds.SignedIdentity, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading signed identity: %w", err)
+	}
+
+	var id imds.SignedIdentity
+	if err = json.Unmarshal(data, &id); err != nil {
+		return nil, fmt.Errorf("parsing signed identity %s: %w", path, err)
+	}
+
+	return &id, nil
+}
+
 func init() {
 	imdsCmd.AddCommand(imdsIdentityCmd)
+
+	imdsIdentityCmd.Flags().BoolVar(&imdsIdentityOpts.verify, "verify", false, "Verify the rsa2048 signature against the bundled AWS certificates")
+	imdsIdentityCmd.Flags().BoolVar(&imdsIdentityOpts.signed, "signed", false, "Print the document and its signatures as one JSON object")
+	imdsIdentityCmd.Flags().StringVar(&imdsIdentityOpts.from, "from", "", "Read a signed identity from this file (- for stdin) instead of IMDS")
 }
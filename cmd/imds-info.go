@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -17,26 +18,32 @@ var imdsInfoCmd = &cobra.Command{
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		client := imds.NewClient()
+		client := imdsOpts.NewClient()
 
 		info := []struct {
 			label string
-			fn    func(context.Context) (string, error)
+			path  string
 		}{
-			{"Instance ID", client.GetInstanceID},
-			{"Instance Type", client.GetInstanceType},
-			{"Region", client.GetRegion},
-			{"Availability Zone", client.GetAvailabilityZone},
-			{"Local IPv4", client.GetLocalIPv4},
-			{"Public IPv4", client.GetPublicIPv4},
+			{"Instance ID", "instance-id"},
+			{"Instance Type", "instance-type"},
+			{"Region", "placement/region"},
+			{"Availability Zone", "placement/availability-zone"},
+			{"Local IPv4", "local-ipv4"},
+			{"Public IPv4", "public-ipv4"},
 		}
 
-		for _, item := range info {
-			value, err := item.fn(ctx)
-			if err != nil {
-				fmt.Printf("%-20s: ERROR - %v\n", item.label, err)
+		paths := make([]string, len(info))
+		for i, item := range info {
+			paths[i] = item.path
+		}
+
+		for i, result := range client.GetMetadataBatch(ctx, paths...) {
+			if errors.Is(result.Err, imds.ErrNotFound) {
+				fmt.Printf("%-20s: not assigned\n", info[i].label)
+			} else if result.Err != nil {
+				fmt.Printf("%-20s: ERROR - %v\n", info[i].label, result.Err)
 			} else {
-				fmt.Printf("%-20s: %s\n", item.label, value)
+				fmt.Printf("%-20s: %s\n", info[i].label, result.Value)
 			}
 		}
 
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/imds"
+)
+
+var imdsServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a local IMDSv2 emulator from a fixture file",
+	Long: `Serve a YAML or JSON fixture with IMDSv2 semantics, for running cloud-init
+scripts on a laptop or in CI.
+
+Tokens are issued by PUT /latest/api/token and required on every request
+unless --allow-v1 is given. Maps in the fixture are served as directory
+listings, and the errors section makes paths fail with a status code.
+Point the other commands at the emulator with
+AWS_EC2_METADATA_SERVICE_ENDPOINT, e.g.
+
+  cloud-init-helper imds serve --fixture imds.yaml &
+  AWS_EC2_METADATA_SERVICE_ENDPOINT=http://127.0.0.1:1338 cloud-init-helper imds info
+
+Fixture:
+  meta-data:
+    instance-id: i-1234567890abcdef0
+    placement:
+      availability-zone: us-west-2a
+    public-keys:
+      0=my-key:
+        openssh-key: ssh-ed25519 AAAA...
+  dynamic:
+    instance-identity:
+      document: '{"region": "us-west-2"}'
+  user-data: |
+    #cloud-config
+  errors:
+    - path: /latest/meta-data/public-ipv4
+      status: 404`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fixture, err := imds.LoadFixture(imdsServeOpts.fixture)
+		cobra.CheckErr(err)
+
+		ln, err := net.Listen("tcp", imdsServeOpts.listen)
+		cobra.CheckErr(err)
+
+		server := &http.Server{
+			Handler:           imds.NewEmulator(fixture, imds.WithAllowV1(imdsServeOpts.allowV1)),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		go func() {
+			<-ctx.Done()
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdownCtx)
+		}()
+
+		cmd.Printf("serving IMDS on http://%s\n", ln.Addr())
+		cmd.Printf("export %s=http://%s\n", imds.EndpointEnv, ln.Addr())
+
+		if err = server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			cobra.CheckErr(err)
+		}
+	},
+}
+
+var imdsServeOpts = imdsServeOptions{
+	listen: "127.0.0.1:1338",
+}
+
+type imdsServeOptions struct {
+	fixture string
+	listen  string
+	allowV1 bool
+}
+
+func init() {
+	imdsCmd.AddCommand(imdsServeCmd)
+
+	imdsServeCmd.Flags().StringVar(&imdsServeOpts.fixture, "fixture", imdsServeOpts.fixture, "YAML or JSON file with the metadata to serve")
+	_ = imdsServeCmd.MarkFlagRequired("fixture")
+	imdsServeCmd.Flags().StringVar(&imdsServeOpts.listen, "listen", imdsServeOpts.listen, "Address to listen on")
+	imdsServeCmd.Flags().BoolVar(&imdsServeOpts.allowV1, "allow-v1", imdsServeOpts.allowV1, "Answer requests without a token, like HttpTokens=optional")
+}
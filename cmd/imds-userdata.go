@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/tempusbreve/cloud-init-helper/internal/imds"
 )
 
 var imdsUserdataCmd = &cobra.Command{
@@ -17,7 +16,7 @@ var imdsUserdataCmd = &cobra.Command{
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		client := imds.NewClient()
+		client := imdsOpts.NewClient()
 
 		userdata, err := client.GetUserData(ctx)
 		if err != nil {
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var imdsWaitCmd = &cobra.Command{
+	Use:   "wait",
+	Short: "Wait until IMDS answers",
+	Long: `Block until the Instance Metadata Service answers, retrying with backoff.
+
+Early in boot IMDS can be briefly unreachable; run this before other steps
+that read instance metadata. Exits with an error after --timeout.
+
+Examples:
+  cloud-init-helper imds wait --timeout 2m && cloud-init-helper dns register-host --name mx1.example.com`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(cmd.Context(), imdsWaitOpts.timeout)
+		defer cancel()
+
+		start := time.Now()
+		if err := imdsOpts.NewClient().Wait(ctx); err != nil {
+			return err
+		}
+
+		cmd.Printf("IMDS ready after %s\n", time.Since(start).Round(time.Millisecond))
+		return nil
+	},
+}
+
+var imdsWaitOpts = imdsWaitOptions{
+	timeout: 2 * time.Minute,
+}
+
+type imdsWaitOptions struct {
+	timeout time.Duration
+}
+
+func init() {
+	imdsCmd.AddCommand(imdsWaitCmd)
+
+	imdsWaitCmd.Flags().DurationVar(&imdsWaitOpts.timeout, "timeout", imdsWaitOpts.timeout, "How long to wait for IMDS")
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/imdswatch"
+	"github.com/tempusbreve/cloud-init-helper/internal/systemd"
+)
+
+var imdsWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Run hooks on Spot interruption, rebalance and maintenance notices",
+	Long: `Poll IMDS for Spot interruption notices (spot/instance-action), rebalance
+recommendations (events/recommendations/rebalance) and scheduled maintenance
+(events/maintenance/scheduled), and run hook commands when a new notice
+appears.
+
+Hooks run with /bin/sh, one after the other in the order given, and get the
+notice in IMDS_EVENT_KIND (spot-interruption, rebalance or maintenance),
+IMDS_EVENT_ID, IMDS_EVENT_ACTION, IMDS_EVENT_TIME and IMDS_EVENT. Each
+notice runs the hooks once; with --state-file this holds across restarts.
+A failed hook is logged and not retried.
+
+With --systemd-unit the command prints a systemd unit that runs itself;
+--install-unit writes it instead. Credentials the hooks need go into the
+unit's environment file.
+
+Examples:
+  cloud-init-helper imds watch --on-spot 'cloud-init-helper dns deregister-host --name mx1.example.com'
+  cloud-init-helper imds watch --on-event 'logger "imds: $IMDS_EVENT_KIND $IMDS_EVENT_ACTION"' --once
+  cloud-init-helper imds watch --on-spot 'tailscale logout' --install-unit`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if imdsWatchOpts.interval <= 0 {
+			return fmt.Errorf("invalid interval %s: must be positive", imdsWatchOpts.interval)
+		}
+
+		if imdsWatchOpts.printUnit || imdsWatchOpts.installUnit {
+			return imdsWatchUnit(cmd)
+		}
+
+		options := []func(*imdswatch.Watcher){
+			imdswatch.WithClient(imdsOpts.NewClient()),
+			imdswatch.WithInterval(imdsWatchOpts.interval),
+			imdswatch.WithStateFile(imdsWatchOpts.stateFile),
+		}
+		for _, h := range imdsWatchOpts.hooks() {
+			for _, command := range h.commands {
+				options = append(options, imdswatch.WithHook(h.kind, imdswatch.CommandHook(command, imdsWatchOpts.hookTimeout)))
+			}
+		}
+
+		watcher := imdswatch.NewWatcher(options...)
+
+		if imdsWatchOpts.once {
+			_, err := watcher.Check(cmd.Context())
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := watcher.Run(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		return nil
+	},
+}
+
+var imdsWatchOpts = imdsWatchOptions{
+	interval:    imdswatch.DefaultInterval,
+	hookTimeout: 2 * time.Minute,
+	unitName:    "cloud-init-helper-imds-watch",
+	envFile:     "/etc/cloud-init-helper/imds-watch.env",
+	unitState:   "/var/lib/cloud-init-helper/imds-watch.json",
+}
+
+type imdsWatchOptions struct {
+	interval      time.Duration
+	onSpot        []string
+	onRebalance   []string
+	onMaintenance []string
+	onEvent       []string
+	hookTimeout   time.Duration
+	stateFile     string
+	once          bool
+	printUnit     bool
+	installUnit   bool
+	unitName      string
+	envFile       string
+	unitState     string
+}
+
+type imdsWatchHooks struct {
+	flag     string
+	kind     imdswatch.Kind
+	commands []string
+}
+
+// hooks lists the hook commands by flag. The --on-event commands come last
+// and run for every kind, after the kind's own hooks.
+func (o imdsWatchOptions) hooks() []imdsWatchHooks {
+	return []imdsWatchHooks{
+		{"--on-spot", imdswatch.KindSpotInterruption, o.onSpot},
+		{"--on-rebalance", imdswatch.KindRebalance, o.onRebalance},
+		{"--on-maintenance", imdswatch.KindMaintenance, o.onMaintenance},
+		{"--on-event", "", o.onEvent},
+	}
+}
+
+// imdsWatchUnit renders a unit that runs this command with the current
+// options, remembering handled notices in a state file.
+func imdsWatchUnit(cmd *cobra.Command) error {
+	opts := imdsWatchOpts
+
+	if len(opts.onSpot)+len(opts.onRebalance)+len(opts.onMaintenance)+len(opts.onEvent) == 0 {
+		return fmt.Errorf("no hooks: use --on-spot, --on-rebalance, --on-maintenance or --on-event")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating executable: %w", err)
+	}
+
+	stateFile := opts.stateFile
+	if stateFile == "" {
+		stateFile = opts.unitState
+	}
+
+	execStart := []string{exe, "imds", "watch",
+		"--interval", opts.interval.String(),
+		"--hook-timeout", opts.hookTimeout.String(),
+		"--state-file", stateFile,
+	}
+	if imdsOpts.v1Fallback {
+		execStart = append(execStart, "--imds-v1-fallback")
+	}
+	for _, h := range opts.hooks() {
+		for _, command := range h.commands {
+			execStart = append(execStart, h.flag, command)
+		}
+	}
+
+	unit := systemd.Unit{
+		Name:            opts.unitName,
+		Description:     "IMDS interruption and maintenance watcher",
+		ExecStart:       execStart,
+		EnvironmentFile: opts.envFile,
+		RestartSec:      5,
+	}
+
+	if !opts.installUnit {
+		return unit.Render(cmd.OutOrStdout())
+	}
+
+	path, err := unit.Install("")
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("installed %s; put hook credentials in %s and enable with: systemctl enable --now %s\n", path, opts.envFile, unit.FileName())
+	return nil
+}
+
+func init() {
+	imdsCmd.AddCommand(imdsWatchCmd)
+
+	flags := imdsWatchCmd.Flags()
+
+	flags.DurationVar(&imdsWatchOpts.interval, "interval", imdsWatchOpts.interval, "How often to poll IMDS")
+	flags.StringArrayVar(&imdsWatchOpts.onSpot, "on-spot", imdsWatchOpts.onSpot, "Command to run on a Spot interruption notice, can repeat")
+	flags.StringArrayVar(&imdsWatchOpts.onRebalance, "on-rebalance", imdsWatchOpts.onRebalance, "Command to run on a rebalance recommendation, can repeat")
+	flags.StringArrayVar(&imdsWatchOpts.onMaintenance, "on-maintenance", imdsWatchOpts.onMaintenance, "Command to run on scheduled maintenance, can repeat")
+	flags.StringArrayVar(&imdsWatchOpts.onEvent, "on-event", imdsWatchOpts.onEvent, "Command to run on any notice, after the specific hooks, can repeat")
+	flags.DurationVar(&imdsWatchOpts.hookTimeout, "hook-timeout", imdsWatchOpts.hookTimeout, "How long a hook command may run")
+	flags.StringVar(&imdsWatchOpts.stateFile, "state-file", imdsWatchOpts.stateFile, "File remembering handled notices across restarts")
+	flags.BoolVar(&imdsWatchOpts.once, "once", imdsWatchOpts.once, "Check once, run hooks for new notices, then exit")
+	flags.BoolVar(&imdsWatchOpts.printUnit, "systemd-unit", imdsWatchOpts.printUnit, "Print a systemd unit running this command and exit")
+	flags.BoolVar(&imdsWatchOpts.installUnit, "install-unit", imdsWatchOpts.installUnit, "Install the systemd unit and exit")
+	flags.StringVar(&imdsWatchOpts.unitName, "unit-name", imdsWatchOpts.unitName, "Name of the systemd unit")
+	flags.StringVar(&imdsWatchOpts.envFile, "env-file", imdsWatchOpts.envFile, "Environment file for the hooks, referenced by the unit")
+}
@@ -2,14 +2,38 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/imds"
 )
 
 var imdsCmd = &cobra.Command{
-	Use:     "imds",
-	Short:   "AWS Instance Metadata Service (IMDSv2) helper commands",
+	Use:   "imds",
+	Short: "AWS Instance Metadata Service (IMDSv2) helper commands",
+	Long: `AWS Instance Metadata Service (IMDSv2) helper commands.
+
+IMDS is reached at http://169.254.169.254 unless
+AWS_EC2_METADATA_SERVICE_ENDPOINT names another endpoint, or
+AWS_EC2_METADATA_SERVICE_ENDPOINT_MODE=IPv6 selects http://[fd00:ec2::254].
+
+Inside containers the IMDSv2 token response may be dropped by the hop
+limit; --imds-v1-fallback then retries the requests without a token.`,
 	GroupID: toolsGroup,
 }
 
+var imdsOpts = imdsOptions{}
+
+type imdsOptions struct {
+	v1Fallback bool
+}
+
+// NewClient returns an IMDS client for every command that reads instance
+// metadata.
+func (o imdsOptions) NewClient() *imds.Client {
+	return imds.NewClient(imds.WithV1Fallback(o.v1Fallback))
+}
+
 func init() {
 	rootCmd.AddCommand(imdsCmd)
+
+	rootCmd.PersistentFlags().BoolVar(&imdsOpts.v1Fallback, "imds-v1-fallback", imdsOpts.v1Fallback, "Fall back to IMDSv1 when no IMDSv2 token can be fetched")
 }
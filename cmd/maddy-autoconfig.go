@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/autoconfig"
+)
+
+var maddyAutoconfigCmd = &cobra.Command{
+	Use:   "autoconfig",
+	Short: "Mail client autoconfiguration for the maddy server",
+}
+
+var maddyAutoconfigServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve Thunderbird autoconfig and Outlook autodiscover documents",
+	Long: `Serve Thunderbird autoconfig and Outlook autodiscover documents.
+
+Point the autoconfig.<domain> and autodiscover.<domain> names at this
+server (see "dns maddy update-dns --client-host"). Outlook only queries
+autodiscover over HTTPS, so pass --tls-cert and --tls-key, e.g. the
+certificates maddy uses from /etc/maddy/certs.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := autoconfigOpts.Config()
+
+		srv := &http.Server{
+			Addr:              autoconfigOpts.listen,
+			Handler:           cfg.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(shutdownCtx)
+		}()
+
+		cmd.Printf("serving autoconfig for %v on %s\n", cfg.Domains, srv.Addr)
+
+		var err error
+		if autoconfigOpts.tlsCert != "" {
+			err = srv.ListenAndServeTLS(autoconfigOpts.tlsCert, autoconfigOpts.tlsKey)
+		} else {
+			err = srv.ListenAndServe()
+		}
+
+		if !errors.Is(err, http.ErrServerClosed) {
+			cobra.CheckErr(err)
+		}
+	},
+}
+
+var maddyAutoconfigPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the autoconfig or autodiscover document",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := autoconfigOpts.Config()
+		domain := cfg.Domains[0]
+
+		switch autoconfigOpts.format {
+		case "thunderbird":
+			cobra.CheckErr(cfg.WriteThunderbird(cmd.OutOrStdout(), domain))
+		case "autodiscover":
+			cobra.CheckErr(cfg.WriteAutodiscover(cmd.OutOrStdout(), "user@"+domain))
+		default:
+			cobra.CheckErr(fmt.Errorf("invalid format %q: expected thunderbird or autodiscover", autoconfigOpts.format))
+		}
+	},
+}
+
+var autoconfigOpts = autoconfigOptions{
+	listen: ":80",
+	format: "thunderbird",
+}
+
+type autoconfigOptions struct {
+	hostname string
+	domains  []string
+	listen   string
+	tlsCert  string
+	tlsKey   string
+	format   string
+}
+
+func (o autoconfigOptions) Config() autoconfig.Config {
+	return autoconfig.Config{Hostname: o.hostname, Domains: o.domains}
+}
+
+func init() {
+	maddyCmd.AddCommand(maddyAutoconfigCmd)
+	maddyAutoconfigCmd.AddCommand(maddyAutoconfigServeCmd)
+	maddyAutoconfigCmd.AddCommand(maddyAutoconfigPrintCmd)
+
+	pf := maddyAutoconfigCmd.PersistentFlags()
+	pf.StringVarP(&autoconfigOpts.hostname, "hostname", "n", autoconfigOpts.hostname, "Hostname running the maddy server")
+	_ = maddyAutoconfigCmd.MarkPersistentFlagRequired("hostname")
+	pf.StringSliceVarP(&autoconfigOpts.domains, "domain", "d", autoconfigOpts.domains, "Mail domain served by the maddy server, can repeat")
+	_ = maddyAutoconfigCmd.MarkPersistentFlagRequired("domain")
+
+	maddyAutoconfigServeCmd.Flags().StringVarP(&autoconfigOpts.listen, "listen", "l", autoconfigOpts.listen, "Address to listen on")
+	maddyAutoconfigServeCmd.Flags().StringVar(&autoconfigOpts.tlsCert, "tls-cert", autoconfigOpts.tlsCert, "TLS certificate file, enables HTTPS")
+	maddyAutoconfigServeCmd.Flags().StringVar(&autoconfigOpts.tlsKey, "tls-key", autoconfigOpts.tlsKey, "TLS key file")
+	maddyAutoconfigServeCmd.MarkFlagsRequiredTogether("tls-cert", "tls-key")
+
+	maddyAutoconfigPrintCmd.Flags().StringVarP(&autoconfigOpts.format, "format", "f", autoconfigOpts.format, "Document to print: thunderbird or autodiscover")
+}
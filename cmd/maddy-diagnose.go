@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/diagnose"
+)
+
+var maddyDiagnoseCmd = &cobra.Command{
+	Use:   "diagnose",
+	Short: "Check mail deliverability of this instance",
+	Long: `Check mail deliverability of this instance.
+
+Checks that the public IPv4 address (from the instance metadata, or --ip)
+has forward confirmed reverse DNS matching --hostname, that it is not
+listed on common DNS blocklists, and that outbound connections to port 25
+are allowed.
+
+Use --resolver to query a specific DNS server, and --dnsbl to pick the
+blocklist zones to check.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ip := diagnoseOpts.mustAddress(cmd.Context())
+
+		checker := diagnose.NewChecker(diagnoseOpts.options()...)
+
+		failed := 0
+		for _, f := range checker.Run(cmd.Context(), ip, diagnoseOpts.hostname) {
+			cmd.Printf("[%-4s] %-30s %s\n", f.Status, f.Check, f.Message)
+			if f.Advice != "" {
+				cmd.Printf("       %-30s -> %s\n", "", f.Advice)
+			}
+			if f.Status == diagnose.StatusFail {
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			cobra.CheckErr(fmt.Errorf("%d checks failed", failed))
+		}
+	},
+}
+
+var diagnoseOpts = diagnoseOptions{
+	blocklists: diagnose.DefaultBlocklists,
+	smtpProbe:  diagnose.DefaultSMTPProbe,
+	timeout:    10 * time.Second,
+}
+
+type diagnoseOptions struct {
+	ip         string
+	hostname   string
+	resolver   string
+	blocklists []string
+	smtpProbe  string
+	timeout    time.Duration
+}
+
+func (o diagnoseOptions) options() []func(*diagnose.Checker) {
+	options := []func(*diagnose.Checker){
+		diagnose.WithBlocklists(o.blocklists),
+		diagnose.WithSMTPProbe(o.smtpProbe),
+		diagnose.WithTimeout(o.timeout),
+	}
+
+	if o.resolver != "" {
+		options = append(options, diagnose.WithResolverAddr(o.resolver))
+	}
+
+	return options
+}
+
+func (o diagnoseOptions) mustAddress(ctx context.Context) netip.Addr {
+	ip := o.ip
+	if ip == "" {
+		info, err := metadataOpts.Instance(ctx)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("getting public ipv4 address (use --ip outside a cloud): %w", err))
+		}
+		if info.PublicIPv4 == "" {
+			cobra.CheckErr(fmt.Errorf("no public ipv4 address assigned on %s; use --ip", info.Cloud))
+		}
+		ip = info.PublicIPv4
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	cobra.CheckErr(err)
+	return addr
+}
+
+func init() {
+	maddyCmd.AddCommand(maddyDiagnoseCmd)
+
+	f := maddyDiagnoseCmd.Flags()
+	f.StringVar(&diagnoseOpts.ip, "ip", diagnoseOpts.ip, "Public IP address to check, defaults to the instance's public IPv4 address")
+	f.StringVarP(&diagnoseOpts.hostname, "hostname", "n", diagnoseOpts.hostname, "Mail host name the reverse DNS should point at")
+	f.StringVar(&diagnoseOpts.resolver, "resolver", diagnoseOpts.resolver, "DNS server (host:port) to query instead of the system resolvers")
+	f.StringSliceVar(&diagnoseOpts.blocklists, "dnsbl", diagnoseOpts.blocklists, "DNS blocklist zone to check, can repeat")
+	f.StringVar(&diagnoseOpts.smtpProbe, "smtp-probe", diagnoseOpts.smtpProbe, "SMTP server (host:port) used to test outbound port 25")
+	f.DurationVar(&diagnoseOpts.timeout, "timeout", diagnoseOpts.timeout, "Timeout for each check")
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var metadataInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the instance metadata of this host",
+	Long: `Show the instance ID, type, region, zone, addresses, hostname and tags
+this host's cloud reports. User data is included with -f json.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		info, err := metadataOpts.Instance(cmd.Context())
+		cobra.CheckErr(err)
+
+		switch metadataInfoOpts.format {
+		case "json":
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			cobra.CheckErr(enc.Encode(info))
+		case "table":
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			for _, row := range [][2]string{
+				{"Cloud", info.Cloud},
+				{"Instance ID", info.InstanceID},
+				{"Instance Type", info.InstanceType},
+				{"Region", info.Region},
+				{"Zone", info.Zone},
+				{"Hostname", info.Hostname},
+				{"Public IPv4", info.PublicIPv4},
+				{"Local IPv4", info.LocalIPv4},
+				{"IPv6", info.IPv6},
+			} {
+				if row[1] == "" {
+					row[1] = "not assigned"
+				}
+				fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+			}
+
+			keys := make([]string, 0, len(info.Tags))
+			for k := range info.Tags {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				fmt.Fprintf(tw, "Tag %s\t%s\n", k, info.Tags[k])
+			}
+			_ = tw.Flush()
+		default:
+			cobra.CheckErr(fmt.Errorf("invalid format %q: expected table or json", metadataInfoOpts.format))
+		}
+	},
+}
+
+var metadataInfoOpts = metadataInfoOptions{
+	format: "table",
+}
+
+type metadataInfoOptions struct {
+	format string
+}
+
+func init() {
+	metadataCmd.AddCommand(metadataInfoCmd)
+
+	metadataInfoCmd.Flags().StringVarP(&metadataInfoOpts.format, "format", "f", metadataInfoOpts.format, "Output format: table or json")
+}
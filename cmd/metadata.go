@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/metadata"
+)
+
+var metadataCmd = &cobra.Command{
+	Use:     "metadata",
+	Aliases: []string{"instance"},
+	Short:   "Cloud instance metadata commands",
+	Long: `Cloud instance metadata commands.
+
+The cloud is detected from the DMI sys_vendor and product_name, or given
+with --cloud. The same metadata is used by "dns register-host", "dns ddns",
+"dns maddy update-dns" and "maddy diagnose".`,
+	GroupID: toolsGroup,
+}
+
+var metadataOpts = metadataOptions{
+	cloud: "auto",
+}
+
+type metadataOptions struct {
+	cloud       string
+	configDrive string
+}
+
+func (o metadataOptions) Provider() (metadata.Provider, error) {
+	cfg := metadata.Config{
+		IMDSv1Fallback: imdsOpts.v1Fallback,
+		ConfigDrive:    o.configDrive,
+	}
+
+	if o.cloud == "auto" {
+		p, err := metadata.DetectProvider(metadata.DefaultDMIPath, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("detecting cloud (use --cloud): %w", err)
+		}
+		return p, nil
+	}
+
+	return metadata.New(o.cloud, cfg)
+}
+
+func (o metadataOptions) MustProvider() metadata.Provider {
+	p, err := o.Provider()
+	cobra.CheckErr(err)
+	return p
+}
+
+// Instance reads the instance metadata, giving up after 30 seconds.
+func (o metadataOptions) Instance(ctx context.Context) (*metadata.InstanceInfo, error) {
+	p, err := o.Provider()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	info, err := p.Instance(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s instance metadata: %w", p.Name(), err)
+	}
+
+	return info, nil
+}
+
+// Addresses reads only the instance addresses, giving up after 30 seconds.
+func (o metadataOptions) Addresses(ctx context.Context) (*metadata.Addresses, error) {
+	p, err := o.Provider()
+	if err != nil {
+		return nil, err
+	}
+
+	return providerAddresses(ctx, p)
+}
+
+func providerAddresses(ctx context.Context, p metadata.Provider) (*metadata.Addresses, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	addrs, err := p.Addresses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s instance addresses: %w", p.Name(), err)
+	}
+
+	return addrs, nil
+}
+
+func init() {
+	rootCmd.AddCommand(metadataCmd)
+
+	var names []string
+	for _, c := range metadata.Clouds() {
+		names = append(names, c.Name)
+	}
+
+	rootCmd.PersistentFlags().StringVar(&metadataOpts.cloud, "cloud", metadataOpts.cloud, "Cloud to read instance metadata from (auto, "+strings.Join(names, ", ")+")")
+	rootCmd.PersistentFlags().StringVar(&metadataOpts.configDrive, "config-drive", metadataOpts.configDrive, "Directory an OpenStack config drive is mounted on")
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/reports"
+	"github.com/tempusbreve/cloud-init-helper/internal/tlsrpt"
+)
+
+var tlsrptReportCmd = &cobra.Command{
+	Use:   "report PATH...",
+	Short: "Summarize SMTP TLS reports",
+	Long: `Summarize RFC 8460 SMTP TLS reports by policy domain.
+
+Each PATH is a report file (JSON or gzip), a mail message carrying reports,
+or a directory or maildir of them, e.g. the mailbox of the rua address
+published in _smtp._tls by "dns maddy update-dns".
+
+Failures show which sending MTAs could not deliver over TLS and why, e.g.
+an expired certificate or an MTA-STS policy that could not be fetched.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var parsed []*tlsrpt.Report
+		for _, path := range args {
+			payloads, err := reports.Read(path)
+			cobra.CheckErr(err)
+
+			for _, p := range payloads {
+				if p.Err != nil {
+					cmd.PrintErrf("skipping %s: %v\n", p.Source, p.Err)
+					continue
+				}
+
+				r, err := tlsrpt.Parse(p.Data)
+				if errors.Is(err, tlsrpt.ErrNotTLSReport) {
+					continue
+				}
+				if err != nil {
+					cmd.PrintErrf("skipping %s: %v\n", p.Source, err)
+					continue
+				}
+				parsed = append(parsed, r)
+			}
+		}
+
+		summary := tlsrpt.Summarize(parsed)
+
+		switch tlsrptOpts.format {
+		case "json":
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			cobra.CheckErr(enc.Encode(summary))
+		case "table":
+			printTLSRPTSummary(cmd, summary)
+		default:
+			cobra.CheckErr(fmt.Errorf("invalid format %q: expected table or json", tlsrptOpts.format))
+		}
+	},
+}
+
+func printTLSRPTSummary(cmd *cobra.Command, s *tlsrpt.Summary) {
+	cmd.Printf("%d reports\n", s.Reports)
+	if s.Reports == 0 {
+		return
+	}
+	cmd.Printf("period %s to %s\n\n", s.Begin.Format("2006-01-02"), s.End.Format("2006-01-02"))
+
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOMAIN\tSUCCESSFUL\tFAILED\tPOLICY TYPES\tREPORTERS")
+	for _, d := range s.Domains {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", d.Domain, d.Successful, d.Failed, counts(d.PolicyTypes), strings.Join(d.Reporters, ","))
+	}
+	_ = tw.Flush()
+
+	for _, d := range s.Domains {
+		if d.Failed == 0 {
+			continue
+		}
+
+		cmd.Printf("\n%s failures\n", d.Domain)
+		cmd.Printf("  result types:  %s\n", counts(d.FailureTypes))
+		cmd.Printf("  sending MTAs:  %s\n", counts(d.SendingMTAs))
+		cmd.Printf("  receiving MXs: %s\n", counts(d.ReceivingMXs))
+	}
+}
+
+func counts(m map[string]int) string {
+	var parts []string
+	for _, k := range tlsrpt.Keys(m) {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, m[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+var tlsrptOpts = tlsrptOptions{format: "table"}
+
+type tlsrptOptions struct {
+	format string
+}
+
+func init() {
+	tlsrptCmd.AddCommand(tlsrptReportCmd)
+
+	tlsrptReportCmd.Flags().StringVarP(&tlsrptOpts.format, "format", "f", tlsrptOpts.format, "Output format: table or json")
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var tlsrptCmd = &cobra.Command{
+	Use:     "tlsrpt",
+	Short:   "SMTP TLS report (TLS-RPT) commands",
+	GroupID: toolsGroup,
+}
+
+func init() {
+	rootCmd.AddCommand(tlsrptCmd)
+}
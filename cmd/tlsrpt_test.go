@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A file that cannot be decoded is reported and skipped, and the reports
+// next to it are still summarized.
+func TestTLSRPTReport_BadFile(t *testing.T) {
+	report, err := os.ReadFile("../internal/tlsrpt/testdata/report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"a.json":    report,
+		"b.json.gz": {0x1f, 0x8b, 0x08, 0x00, 'x'},
+		"c.json":    report,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := execute(t, "tlsrpt", "report", dir, "--format", "json")
+
+	if !strings.Contains(out, "skipping "+filepath.Join(dir, "b.json.gz")) {
+		t.Errorf("Expected a warning for the corrupt file, got %q", out)
+	}
+	if !strings.Contains(out, `"reports": 2`) {
+		t.Errorf("Expected both good reports in the summary, got %q", out)
+	}
+}
@@ -0,0 +1,220 @@
+package autoconfig
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Config describes the mail server clients should be configured for. The
+// ports are maddy's defaults.
+type Config struct {
+	Hostname string
+	Domains  []string
+}
+
+func (c Config) domainFor(candidate string) string {
+	candidate = strings.ToLower(candidate)
+	for _, d := range c.Domains {
+		if strings.EqualFold(d, candidate) || strings.HasSuffix(candidate, "."+strings.ToLower(d)) {
+			return d
+		}
+	}
+
+	if len(c.Domains) > 0 {
+		return c.Domains[0]
+	}
+
+	return candidate
+}
+
+type clientConfig struct {
+	XMLName  xml.Name      `xml:"clientConfig"`
+	Version  string        `xml:"version,attr"`
+	Provider emailProvider `xml:"emailProvider"`
+}
+
+type emailProvider struct {
+	ID               string   `xml:"id,attr"`
+	Domain           string   `xml:"domain"`
+	DisplayName      string   `xml:"displayName"`
+	DisplayShortName string   `xml:"displayShortName"`
+	Incoming         []server `xml:"incomingServer"`
+	Outgoing         []server `xml:"outgoingServer"`
+}
+
+type server struct {
+	Type           string `xml:"type,attr"`
+	Hostname       string `xml:"hostname"`
+	Port           int    `xml:"port"`
+	SocketType     string `xml:"socketType"`
+	Authentication string `xml:"authentication"`
+	Username       string `xml:"username"`
+}
+
+// WriteThunderbird writes the Thunderbird autoconfig (config-v1.1.xml)
+// document for domain.
+func (c Config) WriteThunderbird(w io.Writer, domain string) error {
+	srv := func(typ string, port int, socket string) server {
+		return server{
+			Type:           typ,
+			Hostname:       c.Hostname,
+			Port:           port,
+			SocketType:     socket,
+			Authentication: "password-cleartext",
+			Username:       "%EMAILADDRESS%",
+		}
+	}
+
+	doc := clientConfig{
+		Version: "1.1",
+		Provider: emailProvider{
+			ID:               domain,
+			Domain:           domain,
+			DisplayName:      domain + " Mail",
+			DisplayShortName: domain,
+			Incoming:         []server{srv("imap", 993, "SSL"), srv("imap", 143, "STARTTLS")},
+			Outgoing:         []server{srv("smtp", 465, "SSL"), srv("smtp", 587, "STARTTLS")},
+		},
+	}
+
+	return writeXML(w, doc)
+}
+
+type autodiscoverResponse struct {
+	XMLName  xml.Name        `xml:"http://schemas.microsoft.com/exchange/autodiscover/responseschema/2006 Autodiscover"`
+	Response outlookResponse `xml:"http://schemas.microsoft.com/exchange/autodiscover/outlook/responseschema/2006a Response"`
+}
+
+type outlookResponse struct {
+	Account outlookAccount `xml:"Account"`
+}
+
+type outlookAccount struct {
+	AccountType string            `xml:"AccountType"`
+	Action      string            `xml:"Action"`
+	Protocols   []outlookProtocol `xml:"Protocol"`
+}
+
+type outlookProtocol struct {
+	Type           string `xml:"Type"`
+	Server         string `xml:"Server"`
+	Port           int    `xml:"Port"`
+	DomainRequired string `xml:"DomainRequired"`
+	LoginName      string `xml:"LoginName"`
+	SPA            string `xml:"SPA"`
+	SSL            string `xml:"SSL"`
+	AuthRequired   string `xml:"AuthRequired"`
+}
+
+// WriteAutodiscover writes the Outlook autodiscover (POX) response for the
+// given email address.
+func (c Config) WriteAutodiscover(w io.Writer, email string) error {
+	proto := func(typ string, port int) outlookProtocol {
+		return outlookProtocol{
+			Type:           typ,
+			Server:         c.Hostname,
+			Port:           port,
+			DomainRequired: "off",
+			LoginName:      email,
+			SPA:            "off",
+			SSL:            "on",
+			AuthRequired:   "on",
+		}
+	}
+
+	doc := autodiscoverResponse{
+		Response: outlookResponse{
+			Account: outlookAccount{
+				AccountType: "email",
+				Action:      "settings",
+				Protocols:   []outlookProtocol{proto("IMAP", 993), proto("SMTP", 465)},
+			},
+		},
+	}
+
+	return writeXML(w, doc)
+}
+
+type autodiscoverRequest struct {
+	Request struct {
+		EMailAddress string `xml:"EMailAddress"`
+	} `xml:"Request"`
+}
+
+// Handler serves the Thunderbird autoconfig and Outlook autodiscover
+// documents on their well-known paths.
+func (c Config) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	thunderbird := func(w http.ResponseWriter, r *http.Request) {
+		domain := hostDomain(r.Host)
+		if email := r.URL.Query().Get("emailaddress"); strings.Contains(email, "@") {
+			domain = email[strings.LastIndex(email, "@")+1:]
+		}
+
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		if err := c.WriteThunderbird(w, c.domainFor(domain)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+
+	autodiscover := func(w http.ResponseWriter, r *http.Request) {
+		var req autodiscoverRequest
+		if r.Method == http.MethodPost {
+			if err := xml.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("invalid autodiscover request: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		email := req.Request.EMailAddress
+		if email == "" {
+			email = r.URL.Query().Get("email")
+		}
+
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		if err := c.WriteAutodiscover(w, email); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+
+	mux.HandleFunc("/mail/config-v1.1.xml", thunderbird)
+	mux.HandleFunc("/.well-known/autoconfig/mail/config-v1.1.xml", thunderbird)
+	mux.HandleFunc("/autodiscover/autodiscover.xml", autodiscover)
+	mux.HandleFunc("/Autodiscover/Autodiscover.xml", autodiscover)
+
+	return mux
+}
+
+// hostDomain strips the port and any autoconfig/autodiscover label from a
+// request Host.
+func hostDomain(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, prefix := range []string{"autoconfig.", "autodiscover."} {
+		host = strings.TrimPrefix(host, prefix)
+	}
+
+	return host
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encoding xml: %w", err)
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,89 @@
+package autoconfig
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_Thunderbird(t *testing.T) {
+	cfg := Config{Hostname: "mx1.example.com", Domains: []string{"example.com", "example.org"}}
+	server := httptest.NewServer(cfg.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/mail/config-v1.1.xml?emailaddress=user@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	for _, want := range []string{
+		`<clientConfig version="1.1">`,
+		`<emailProvider id="example.org">`,
+		`<incomingServer type="imap">`,
+		`<hostname>mx1.example.com</hostname>`,
+		`<port>993</port>`,
+		`<port>587</port>`,
+		`<username>%EMAILADDRESS%</username>`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected response to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestHandler_Autodiscover(t *testing.T) {
+	cfg := Config{Hostname: "mx1.example.com", Domains: []string{"example.com"}}
+	server := httptest.NewServer(cfg.Handler())
+	defer server.Close()
+
+	req := `<?xml version="1.0" encoding="utf-8"?>
+<Autodiscover xmlns="http://schemas.microsoft.com/exchange/autodiscover/outlook/requestschema/2006">
+  <Request>
+    <EMailAddress>user@example.com</EMailAddress>
+    <AcceptableResponseSchema>http://schemas.microsoft.com/exchange/autodiscover/outlook/responseschema/2006a</AcceptableResponseSchema>
+  </Request>
+</Autodiscover>`
+
+	resp, err := http.Post(server.URL+"/autodiscover/autodiscover.xml", "text/xml", strings.NewReader(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	for _, want := range []string{
+		`<Autodiscover xmlns="http://schemas.microsoft.com/exchange/autodiscover/responseschema/2006">`,
+		`<Type>IMAP</Type>`,
+		`<Type>SMTP</Type>`,
+		`<Server>mx1.example.com</Server>`,
+		`<LoginName>user@example.com</LoginName>`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected response to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestHostDomain(t *testing.T) {
+	for in, want := range map[string]string{
+		"autoconfig.example.com":      "example.com",
+		"autodiscover.example.com:80": "example.com",
+		"example.com":                 "example.com",
+	} {
+		if got := hostDomain(in); got != want {
+			t.Errorf("hostDomain(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
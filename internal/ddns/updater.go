@@ -0,0 +1,162 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+)
+
+const defaultInterval = 5 * time.Minute
+
+var (
+	ErrInvalidAddress  = errors.New("invalid address")
+	ErrInvalidInterval = errors.New("invalid interval")
+)
+
+// AddressFunc reports the current address of the host.
+type AddressFunc func(context.Context) (string, error)
+
+// Updater keeps a single DNS record pointed at the host's current address.
+type Updater struct {
+	api      dns.API
+	name     string
+	rtype    dns.RecordType
+	source   AddressFunc
+	interval time.Duration
+	logger   *log.Logger
+
+	last string
+}
+
+func WithAPI(api dns.API) func(*Updater) { return func(u *Updater) { u.api = api } }
+
+func WithName(name string) func(*Updater) { return func(u *Updater) { u.name = name } }
+
+func WithRecordType(rtype dns.RecordType) func(*Updater) {
+	return func(u *Updater) { u.rtype = rtype }
+}
+
+func WithSource(source AddressFunc) func(*Updater) { return func(u *Updater) { u.source = source } }
+
+func WithInterval(interval time.Duration) func(*Updater) {
+	return func(u *Updater) { u.interval = interval }
+}
+
+func WithLogger(logger *log.Logger) func(*Updater) { return func(u *Updater) { u.logger = logger } }
+
+func NewUpdater(options ...func(*Updater)) *Updater {
+	u := &Updater{
+		rtype:    dns.RecordTypeA,
+		interval: defaultInterval,
+		logger:   log.Default(),
+	}
+
+	for _, fn := range options {
+		fn(u)
+	}
+
+	return u
+}
+
+// Check fetches the current address and reconciles the record if the
+// address differs from the one last seen. It reports whether it did so.
+func (u *Updater) Check(ctx context.Context) (bool, error) {
+	addr, err := u.source(ctx)
+	if err != nil {
+		return false, fmt.Errorf("getting current address: %w", err)
+	}
+
+	addr, err = validate(u.rtype, addr)
+	if err != nil {
+		return false, err
+	}
+
+	if addr == u.last {
+		return false, nil
+	}
+
+	if err = dns.SetRecords(ctx, u.api, u.name, u.rtype, addr); err != nil {
+		return false, fmt.Errorf("updating %s %s: %w", u.rtype, u.name, err)
+	}
+
+	u.last = addr
+	u.logger.Printf("%s %s :: %s", u.rtype, u.name, addr)
+
+	return true, nil
+}
+
+// Run checks the address every interval until ctx is done. Failed checks
+// are logged and retried on the next tick.
+func (u *Updater) Run(ctx context.Context) error {
+	if u.interval <= 0 {
+		return fmt.Errorf("%w %s: must be positive", ErrInvalidInterval, u.interval)
+	}
+
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := u.Check(ctx); err != nil {
+			u.logger.Printf("ddns check failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeURL returns an AddressFunc that reads the address from a plain text
+// "what is my IP" style endpoint.
+func ProbeURL(client *http.Client, url string) AddressFunc {
+	return func(ctx context.Context) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return "", fmt.Errorf("creating request: %w", err)
+		}
+		req.Header.Set("User-Agent", "cloud-init-helper")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("probing %q: %w", url, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("probing %q: %s", url, resp.Status)
+		}
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+		if err != nil {
+			return "", fmt.Errorf("reading probe response: %w", err)
+		}
+
+		return strings.TrimSpace(string(body)), nil
+	}
+}
+
+func validate(rtype dns.RecordType, addr string) (string, error) {
+	ip, err := netip.ParseAddr(strings.TrimSpace(addr))
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidAddress, addr)
+	}
+
+	switch {
+	case rtype == dns.RecordTypeA && !ip.Is4():
+		return "", fmt.Errorf("%w: %s is not an IPv4 address", ErrInvalidAddress, ip)
+	case rtype == dns.RecordTypeAAAA && (!ip.Is6() || ip.Is4In6()):
+		return "", fmt.Errorf("%w: %s is not an IPv6 address", ErrInvalidAddress, ip)
+	}
+
+	return ip.String(), nil
+}
@@ -0,0 +1,147 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns"
+)
+
+type memoryAPI struct {
+	records map[string]dns.Record
+	next    int
+	creates int
+	deletes int
+}
+
+func newMemoryAPI() *memoryAPI { return &memoryAPI{records: map[string]dns.Record{}} }
+
+func (m *memoryAPI) GetRecords(_ context.Context, name, rtype string) ([]dns.Record, error) {
+	var res []dns.Record
+	for _, rec := range m.records {
+		if rec.Name() == name && string(rec.Type()) == rtype {
+			res = append(res, rec)
+		}
+	}
+	return res, nil
+}
+
+func (m *memoryAPI) CreateMXRecord(ctx context.Context, name, host string, weight int) error {
+	return m.CreateRecord(ctx, dns.NewMXRecord(name, host, weight))
+}
+
+func (m *memoryAPI) GetRecord(_ context.Context, id any) (dns.Record, error) {
+	if rec, ok := m.records[id.(string)]; ok {
+		return rec, nil
+	}
+	return nil, dns.ErrRecordNotFound
+}
+
+func (m *memoryAPI) CreateRecord(_ context.Context, rec dns.Record) error {
+	m.next++
+	m.creates++
+	id := strconv.Itoa(m.next)
+	m.records[id] = dns.NewRecordWithID(id, rec.Name(), rec.Type(), rec.Content())
+	return nil
+}
+
+func (m *memoryAPI) UpdateRecord(_ context.Context, rec dns.Record) error {
+	m.records[rec.ID().(string)] = rec
+	return nil
+}
+
+func (m *memoryAPI) DeleteRecord(_ context.Context, id any) error {
+	m.deletes++
+	delete(m.records, id.(string))
+	return nil
+}
+
+func TestUpdater_Check(t *testing.T) {
+	api := newMemoryAPI()
+	addr := "192.0.2.1"
+
+	u := NewUpdater(
+		WithAPI(api),
+		WithName("host.example.com"),
+		WithSource(func(context.Context) (string, error) { return addr, nil }),
+		WithLogger(log.New(io.Discard, "", 0)),
+	)
+	ctx := context.Background()
+
+	if changed, err := u.Check(ctx); err != nil || !changed {
+		t.Fatalf("Expected first check to publish, got %v, %v", changed, err)
+	}
+
+	if changed, err := u.Check(ctx); err != nil || changed {
+		t.Fatalf("Expected unchanged address to be skipped, got %v, %v", changed, err)
+	}
+
+	if api.creates != 1 || api.deletes != 0 {
+		t.Errorf("Expected 1 create and 0 deletes, got %d and %d", api.creates, api.deletes)
+	}
+
+	addr = "192.0.2.2"
+	if changed, err := u.Check(ctx); err != nil || !changed {
+		t.Fatalf("Expected new address to publish, got %v, %v", changed, err)
+	}
+
+	records, _ := api.GetRecords(ctx, "host.example.com", "A")
+	if len(records) != 1 || records[0].Content() != "192.0.2.2" {
+		t.Errorf("Expected single record with new address, got %v", records)
+	}
+}
+
+func TestUpdater_InvalidAddress(t *testing.T) {
+	api := newMemoryAPI()
+
+	u := NewUpdater(
+		WithAPI(api),
+		WithName("host.example.com"),
+		WithSource(func(context.Context) (string, error) { return "2001:db8::1", nil }),
+		WithLogger(log.New(io.Discard, "", 0)),
+	)
+
+	if _, err := u.Check(context.Background()); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Expected ErrInvalidAddress for IPv6 address on A record, got %v", err)
+	}
+
+	if api.creates != 0 {
+		t.Error("Expected no records to be created")
+	}
+}
+
+func TestUpdater_InvalidInterval(t *testing.T) {
+	u := NewUpdater(
+		WithAPI(newMemoryAPI()),
+		WithName("host.example.com"),
+		WithSource(func(context.Context) (string, error) { return "192.0.2.1", nil }),
+		WithInterval(0),
+		WithLogger(log.New(io.Discard, "", 0)),
+	)
+
+	if err := u.Run(context.Background()); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("Expected ErrInvalidInterval, got %v", err)
+	}
+}
+
+func TestProbeURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("198.51.100.7\n"))
+	}))
+	defer server.Close()
+
+	addr, err := ProbeURL(server.Client(), server.URL)(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if addr != "198.51.100.7" {
+		t.Errorf("Expected address '198.51.100.7', got %q", addr)
+	}
+}
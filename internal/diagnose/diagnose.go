@@ -0,0 +1,242 @@
+package diagnose
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultBlocklists are widely used IP blocklists that reject or spam-folder
+// mail from listed addresses.
+var DefaultBlocklists = []string{
+	"zen.spamhaus.org",
+	"bl.spamcop.net",
+	"b.barracudacentral.org",
+	"psbl.surriel.com",
+}
+
+// DefaultSMTPProbe is a mail exchanger that accepts connections from
+// anywhere, used to find out whether outbound port 25 is blocked.
+const DefaultSMTPProbe = "gmail-smtp-in.l.google.com:25"
+
+type Status string
+
+const (
+	StatusOK   = Status("ok")
+	StatusWarn = Status("warn")
+	StatusFail = Status("fail")
+)
+
+// Finding is the result of a single check, with advice when it did not pass.
+type Finding struct {
+	Check   string
+	Status  Status
+	Message string
+	Advice  string
+}
+
+type Checker struct {
+	resolver   *net.Resolver
+	dialer     *net.Dialer
+	blocklists []string
+	smtpProbe  string
+	timeout    time.Duration
+}
+
+// WithResolverAddr sends all DNS queries to the server at addr (host:port)
+// instead of the system resolvers.
+func WithResolverAddr(addr string) func(*Checker) {
+	return func(c *Checker) {
+		c.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+	}
+}
+
+func WithBlocklists(zones []string) func(*Checker) {
+	return func(c *Checker) { c.blocklists = zones }
+}
+
+func WithSMTPProbe(addr string) func(*Checker) {
+	return func(c *Checker) { c.smtpProbe = addr }
+}
+
+func WithTimeout(timeout time.Duration) func(*Checker) {
+	return func(c *Checker) { c.timeout = timeout }
+}
+
+func NewChecker(options ...func(*Checker)) *Checker {
+	c := &Checker{
+		resolver:   net.DefaultResolver,
+		dialer:     &net.Dialer{},
+		blocklists: DefaultBlocklists,
+		smtpProbe:  DefaultSMTPProbe,
+		timeout:    10 * time.Second,
+	}
+
+	for _, fn := range options {
+		fn(c)
+	}
+
+	c.dialer.Resolver = c.resolver
+
+	return c
+}
+
+// Run performs all checks for the public address ip of the mail server
+// hostname.
+func (c *Checker) Run(ctx context.Context, ip netip.Addr, hostname string) []Finding {
+	findings := []Finding{c.CheckFCrDNS(ctx, ip, hostname)}
+	findings = append(findings, c.CheckBlocklists(ctx, ip)...)
+	return append(findings, c.CheckPort25(ctx))
+}
+
+// CheckFCrDNS checks that ip has a PTR record whose name resolves back to
+// ip, and that the name is hostname when one is given.
+func (c *Checker) CheckFCrDNS(ctx context.Context, ip netip.Addr, hostname string) Finding {
+	const check = "fcrdns"
+
+	advice := "set the reverse DNS of the Elastic IP to " + orDefault(hostname, "the mail host name") +
+		" (EC2 console: Elastic IPs > Update reverse DNS), after its A record exists"
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	names, err := c.resolver.LookupAddr(ctx, ip.String())
+	if err != nil || len(names) == 0 {
+		return Finding{Check: check, Status: StatusFail, Message: fmt.Sprintf("no PTR record for %s: %v", ip, err), Advice: advice}
+	}
+
+	var confirmed []string
+	for _, name := range names {
+		addrs, err := c.resolver.LookupNetIP(ctx, "ip", name)
+		if err != nil {
+			continue
+		}
+
+		if slices.ContainsFunc(addrs, func(a netip.Addr) bool { return a.Unmap() == ip.Unmap() }) {
+			confirmed = append(confirmed, strings.TrimSuffix(name, "."))
+		}
+	}
+
+	if len(confirmed) == 0 {
+		return Finding{Check: check, Status: StatusFail, Message: fmt.Sprintf("PTR %v for %s does not resolve back to it", names, ip), Advice: advice}
+	}
+
+	if hostname != "" && !slices.ContainsFunc(confirmed, func(n string) bool { return strings.EqualFold(n, strings.TrimSuffix(hostname, ".")) }) {
+		return Finding{Check: check, Status: StatusWarn, Message: fmt.Sprintf("%s reverse resolves to %v, not %s", ip, confirmed, hostname), Advice: advice}
+	}
+
+	return Finding{Check: check, Status: StatusOK, Message: fmt.Sprintf("%s <-> %s", ip, confirmed[0])}
+}
+
+// CheckBlocklists looks ip up in each DNSBL zone. Only IPv4 addresses are
+// checked, as most blocklists do not list IPv6.
+func (c *Checker) CheckBlocklists(ctx context.Context, ip netip.Addr) []Finding {
+	ip = ip.Unmap()
+	if !ip.Is4() {
+		return nil
+	}
+
+	b := ip.As4()
+	reversed := fmt.Sprintf("%d.%d.%d.%d", b[3], b[2], b[1], b[0])
+
+	var findings []Finding
+	for _, zone := range c.blocklists {
+		findings = append(findings, c.checkBlocklist(ctx, ip, reversed+"."+zone, zone))
+	}
+
+	return findings
+}
+
+func (c *Checker) checkBlocklist(ctx context.Context, ip netip.Addr, query, zone string) Finding {
+	check := "dnsbl " + zone
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	addrs, err := c.resolver.LookupHost(ctx, query)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return Finding{Check: check, Status: StatusOK, Message: ip.String() + " is not listed"}
+		}
+
+		return Finding{Check: check, Status: StatusWarn, Message: fmt.Sprintf("lookup failed: %v", err)}
+	}
+
+	// Spamhaus answers 127.255.255.x to queries from public resolvers
+	// instead of listing results.
+	for _, a := range addrs {
+		if strings.HasPrefix(a, "127.255.255.") {
+			return Finding{
+				Check:   check,
+				Status:  StatusWarn,
+				Message: fmt.Sprintf("query refused (%s)", a),
+				Advice:  "query the blocklist through a non-public resolver, e.g. the VPC resolver",
+			}
+		}
+	}
+
+	return Finding{
+		Check:   check,
+		Status:  StatusFail,
+		Message: fmt.Sprintf("%s is listed (%s)", ip, strings.Join(addrs, ", ")),
+		Advice:  "request delisting from " + zone + ", or allocate a new Elastic IP before sending mail",
+	}
+}
+
+// CheckPort25 connects to the SMTP probe and expects a 220 greeting. EC2
+// blocks outbound port 25 until the sending limit is lifted.
+func (c *Checker) CheckPort25(ctx context.Context) Finding {
+	const check = "port 25"
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	conn, err := c.dialer.DialContext(ctx, "tcp", c.smtpProbe)
+	if dnsErr := (*net.DNSError)(nil); errors.As(err, &dnsErr) {
+		return Finding{Check: check, Status: StatusWarn, Message: fmt.Sprintf("cannot resolve %s: %v", c.smtpProbe, err)}
+	}
+	if err != nil {
+		return Finding{
+			Check:   check,
+			Status:  StatusFail,
+			Message: fmt.Sprintf("cannot connect to %s: %v", c.smtpProbe, err),
+			Advice:  "outbound port 25 is likely blocked; request removal of the EC2 email sending limitation",
+		}
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	greeting, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return Finding{Check: check, Status: StatusWarn, Message: fmt.Sprintf("no greeting from %s: %v", c.smtpProbe, err)}
+	}
+
+	if !strings.HasPrefix(greeting, "220") {
+		return Finding{Check: check, Status: StatusWarn, Message: fmt.Sprintf("unexpected greeting from %s: %s", c.smtpProbe, strings.TrimSpace(greeting))}
+	}
+
+	return Finding{Check: check, Status: StatusOK, Message: "outbound connections to " + c.smtpProbe + " succeed"}
+}
+
+func orDefault(s, def string) string {
+	if s != "" {
+		return s
+	}
+	return def
+}
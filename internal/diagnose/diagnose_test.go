@@ -0,0 +1,168 @@
+package diagnose
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNS answers A and PTR queries from a fixed table and NXDOMAIN for
+// everything else.
+type fakeDNS struct {
+	a   map[string]netip.Addr
+	ptr map[string]string
+}
+
+func startFakeDNS(t *testing.T, f *fakeDNS) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if resp, err := f.answer(buf[:n]); err == nil {
+				_, _ = conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func (f *fakeDNS) answer(query []byte) ([]byte, error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		return nil, err
+	}
+
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: msg.ID, Response: true, Authoritative: true, RCode: dnsmessage.RCodeNameError},
+		Questions: msg.Questions,
+	}
+
+	for _, q := range msg.Questions {
+		name := strings.ToLower(q.Name.String())
+		hdr := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60}
+
+		switch {
+		case q.Type == dnsmessage.TypeA && f.a[name].IsValid():
+			resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.AResource{A: f.a[name].As4()}})
+		case q.Type == dnsmessage.TypePTR && f.ptr[name] != "":
+			resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(f.ptr[name])}})
+		case q.Type == dnsmessage.TypeAAAA && (f.a[name].IsValid() || f.ptr[name] != ""):
+			resp.RCode = dnsmessage.RCodeSuccess
+		}
+
+		if len(resp.Answers) > 0 {
+			resp.RCode = dnsmessage.RCodeSuccess
+		}
+	}
+
+	return resp.Pack()
+}
+
+func TestChecker_FCrDNS(t *testing.T) {
+	ip := netip.MustParseAddr("192.0.2.10")
+
+	addr := startFakeDNS(t, &fakeDNS{
+		a: map[string]netip.Addr{
+			"mx1.example.com.":   ip,
+			"other.example.com.": netip.MustParseAddr("192.0.2.99"),
+		},
+		ptr: map[string]string{
+			"10.2.0.192.in-addr.arpa.": "mx1.example.com.",
+			"11.2.0.192.in-addr.arpa.": "other.example.com.",
+		},
+	})
+	c := NewChecker(WithResolverAddr(addr), WithTimeout(5*time.Second))
+	ctx := context.Background()
+
+	if f := c.CheckFCrDNS(ctx, ip, "mx1.example.com"); f.Status != StatusOK {
+		t.Errorf("Expected ok, got %+v", f)
+	}
+
+	if f := c.CheckFCrDNS(ctx, ip, "mail.example.com"); f.Status != StatusWarn {
+		t.Errorf("Expected warn for another host name, got %+v", f)
+	}
+
+	if f := c.CheckFCrDNS(ctx, netip.MustParseAddr("192.0.2.11"), ""); f.Status != StatusFail || f.Advice == "" {
+		t.Errorf("Expected fail with advice for unconfirmed PTR, got %+v", f)
+	}
+
+	if f := c.CheckFCrDNS(ctx, netip.MustParseAddr("192.0.2.12"), ""); f.Status != StatusFail {
+		t.Errorf("Expected fail without PTR, got %+v", f)
+	}
+}
+
+func TestChecker_Blocklists(t *testing.T) {
+	addr := startFakeDNS(t, &fakeDNS{
+		a: map[string]netip.Addr{
+			"10.2.0.192.listed.test.":  netip.MustParseAddr("127.0.0.2"),
+			"10.2.0.192.refused.test.": netip.MustParseAddr("127.255.255.254"),
+		},
+	})
+	c := NewChecker(WithResolverAddr(addr), WithBlocklists([]string{"listed.test", "clean.test", "refused.test"}))
+
+	findings := c.CheckBlocklists(context.Background(), netip.MustParseAddr("192.0.2.10"))
+
+	want := []Status{StatusFail, StatusOK, StatusWarn}
+	if len(findings) != len(want) {
+		t.Fatalf("Expected %d findings, got %+v", len(want), findings)
+	}
+	for i, f := range findings {
+		if f.Status != want[i] {
+			t.Errorf("%s: expected %s, got %+v", f.Check, want[i], f)
+		}
+	}
+
+	if f := c.CheckBlocklists(context.Background(), netip.MustParseAddr("2001:db8::1")); f != nil {
+		t.Errorf("Expected IPv6 addresses to be skipped, got %+v", f)
+	}
+}
+
+func TestChecker_Port25(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("220 mx.test ESMTP\r\n"))
+			conn.Close()
+		}
+	}()
+
+	c := NewChecker(WithSMTPProbe(ln.Addr().String()), WithTimeout(5*time.Second))
+	if f := c.CheckPort25(context.Background()); f.Status != StatusOK {
+		t.Errorf("Expected ok, got %+v", f)
+	}
+
+	closed := ln.Addr().String()
+	ln.Close()
+
+	c = NewChecker(WithSMTPProbe(closed), WithTimeout(time.Second))
+	if f := c.CheckPort25(context.Background()); f.Status != StatusFail || f.Advice == "" {
+		t.Errorf("Expected fail with advice, got %+v", f)
+	}
+}
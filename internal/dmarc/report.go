@@ -0,0 +1,225 @@
+package dmarc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrNotAggregateReport = errors.New("not a dmarc aggregate report")
+
+// Report is an RFC 7489 aggregate (rua) report.
+type Report struct {
+	XMLName  xml.Name        `xml:"feedback"`
+	Metadata ReportMetadata  `xml:"report_metadata"`
+	Policy   PolicyPublished `xml:"policy_published"`
+	Records  []Record        `xml:"record"`
+}
+
+type ReportMetadata struct {
+	OrgName   string    `xml:"org_name"`
+	Email     string    `xml:"email"`
+	ReportID  string    `xml:"report_id"`
+	DateRange DateRange `xml:"date_range"`
+}
+
+type DateRange struct {
+	Begin int64 `xml:"begin"`
+	End   int64 `xml:"end"`
+}
+
+type PolicyPublished struct {
+	Domain string `xml:"domain"`
+	ADKIM  string `xml:"adkim"`
+	ASPF   string `xml:"aspf"`
+	P      string `xml:"p"`
+	SP     string `xml:"sp"`
+	Pct    int    `xml:"pct"`
+}
+
+type Record struct {
+	Row         Row         `xml:"row"`
+	Identifiers Identifiers `xml:"identifiers"`
+	AuthResults AuthResults `xml:"auth_results"`
+}
+
+type Row struct {
+	SourceIP        string          `xml:"source_ip"`
+	Count           int             `xml:"count"`
+	PolicyEvaluated PolicyEvaluated `xml:"policy_evaluated"`
+}
+
+// PolicyEvaluated holds the aligned DKIM and SPF results that DMARC was
+// evaluated on.
+type PolicyEvaluated struct {
+	Disposition string `xml:"disposition"`
+	DKIM        string `xml:"dkim"`
+	SPF         string `xml:"spf"`
+}
+
+type Identifiers struct {
+	HeaderFrom   string `xml:"header_from"`
+	EnvelopeFrom string `xml:"envelope_from"`
+}
+
+type AuthResults struct {
+	DKIM []DKIMResult `xml:"dkim"`
+	SPF  []SPFResult  `xml:"spf"`
+}
+
+type DKIMResult struct {
+	Domain   string `xml:"domain"`
+	Selector string `xml:"selector"`
+	Result   string `xml:"result"`
+}
+
+type SPFResult struct {
+	Domain string `xml:"domain"`
+	Scope  string `xml:"scope"`
+	Result string `xml:"result"`
+}
+
+// Parse decodes an aggregate report document.
+func Parse(data []byte) (*Report, error) {
+	if !bytes.Contains(data, []byte("<feedback")) {
+		return nil, ErrNotAggregateReport
+	}
+
+	var r Report
+	if err := xml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parsing dmarc report: %w", err)
+	}
+
+	return &r, nil
+}
+
+// Begin and End bound the period the report covers.
+func (r *Report) Begin() time.Time { return time.Unix(r.Metadata.DateRange.Begin, 0).UTC() }
+func (r *Report) End() time.Time   { return time.Unix(r.Metadata.DateRange.End, 0).UTC() }
+
+// Pass reports whether the row's messages passed DMARC: aligned DKIM or
+// aligned SPF.
+func (r Row) Pass() bool {
+	return strings.EqualFold(r.PolicyEvaluated.DKIM, "pass") || strings.EqualFold(r.PolicyEvaluated.SPF, "pass")
+}
+
+// Source summarizes the messages reported for one sending IP address.
+type Source struct {
+	IP          string   `json:"ip"`
+	Domains     []string `json:"domains"`
+	Reporters   []string `json:"reporters"`
+	Messages    int      `json:"messages"`
+	Pass        int      `json:"pass"`
+	Fail        int      `json:"fail"`
+	DKIMAligned int      `json:"dkim_aligned"`
+	SPFAligned  int      `json:"spf_aligned"`
+	Quarantined int      `json:"quarantined"`
+	Rejected    int      `json:"rejected"`
+
+	// Unauthorized is set for sources that failed DMARC, or that are not
+	// in the authorized prefixes when those are given.
+	Unauthorized bool `json:"unauthorized"`
+}
+
+type Summary struct {
+	Reports  int       `json:"reports"`
+	Messages int       `json:"messages"`
+	Pass     int       `json:"pass"`
+	Fail     int       `json:"fail"`
+	Begin    time.Time `json:"begin"`
+	End      time.Time `json:"end"`
+	Sources  []*Source `json:"sources"`
+}
+
+// Summarize aggregates reports by source IP. Sources outside authorized
+// are flagged as unauthorized even when they pass, since a pass from an
+// unknown IP usually means a third party is signing for the domain.
+func Summarize(reports []*Report, authorized []netip.Prefix) *Summary {
+	s := &Summary{Reports: len(reports)}
+	sources := map[string]*Source{}
+
+	for _, r := range reports {
+		if s.Begin.IsZero() || r.Begin().Before(s.Begin) {
+			s.Begin = r.Begin()
+		}
+		if r.End().After(s.End) {
+			s.End = r.End()
+		}
+
+		for _, rec := range r.Records {
+			row := rec.Row
+
+			src, ok := sources[row.SourceIP]
+			if !ok {
+				src = &Source{IP: row.SourceIP}
+				sources[row.SourceIP] = src
+			}
+
+			src.Messages += row.Count
+			s.Messages += row.Count
+
+			if row.Pass() {
+				src.Pass += row.Count
+				s.Pass += row.Count
+			} else {
+				src.Fail += row.Count
+				s.Fail += row.Count
+			}
+
+			if strings.EqualFold(row.PolicyEvaluated.DKIM, "pass") {
+				src.DKIMAligned += row.Count
+			}
+			if strings.EqualFold(row.PolicyEvaluated.SPF, "pass") {
+				src.SPFAligned += row.Count
+			}
+
+			switch strings.ToLower(row.PolicyEvaluated.Disposition) {
+			case "quarantine":
+				src.Quarantined += row.Count
+			case "reject":
+				src.Rejected += row.Count
+			}
+
+			src.Domains = appendUnique(src.Domains, strings.ToLower(rec.Identifiers.HeaderFrom))
+			src.Reporters = appendUnique(src.Reporters, r.Metadata.OrgName)
+		}
+	}
+
+	for _, src := range sources {
+		src.Unauthorized = src.Fail > 0 || (len(authorized) > 0 && !contains(authorized, src.IP))
+		s.Sources = append(s.Sources, src)
+	}
+
+	sort.Slice(s.Sources, func(i, j int) bool {
+		if s.Sources[i].Messages != s.Sources[j].Messages {
+			return s.Sources[i].Messages > s.Sources[j].Messages
+		}
+		return s.Sources[i].IP < s.Sources[j].IP
+	})
+
+	return s
+}
+
+func contains(prefixes []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	return slices.ContainsFunc(prefixes, func(p netip.Prefix) bool { return p.Contains(addr.Unmap()) })
+}
+
+func appendUnique(list []string, v string) []string {
+	if v == "" || slices.Contains(list, v) {
+		return list
+	}
+	list = append(list, v)
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,71 @@
+package dmarc
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	data, err := os.ReadFile("testdata/aggregate.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if r.Metadata.OrgName != "google.com" || r.Policy.Domain != "example.com" || r.Policy.P != "quarantine" {
+		t.Errorf("Unexpected report header: %+v %+v", r.Metadata, r.Policy)
+	}
+
+	if len(r.Records) != 3 || r.Records[0].AuthResults.DKIM[0].Selector != "default" {
+		t.Errorf("Unexpected records: %+v", r.Records)
+	}
+
+	if _, err := Parse([]byte(`{"organization-name":"x"}`)); !errors.Is(err, ErrNotAggregateReport) {
+		t.Errorf("Expected ErrNotAggregateReport, got %v", err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	data, err := os.ReadFile("testdata/aggregate.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Summarize([]*Report{r, r}, nil)
+	if s.Reports != 2 || s.Messages != 34 || s.Pass != 28 || s.Fail != 6 {
+		t.Errorf("Unexpected totals: %+v", s)
+	}
+
+	if len(s.Sources) != 3 || s.Sources[0].IP != "192.0.2.10" {
+		t.Fatalf("Unexpected sources: %+v", s.Sources)
+	}
+
+	unauthorized := map[string]bool{}
+	for _, src := range s.Sources {
+		unauthorized[src.IP] = src.Unauthorized
+	}
+	if unauthorized["192.0.2.10"] || !unauthorized["198.51.100.7"] || unauthorized["203.0.113.5"] {
+		t.Errorf("Unexpected unauthorized flags: %v", unauthorized)
+	}
+
+	s = Summarize([]*Report{r}, []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")})
+	for _, src := range s.Sources {
+		if src.IP == "203.0.113.5" && !src.Unauthorized {
+			t.Error("Expected passing source outside the authorized prefixes to be flagged")
+		}
+		if src.IP == "198.51.100.7" && src.Quarantined != 3 {
+			t.Errorf("Expected quarantined messages to be counted, got %+v", src)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+// Package cftest provides an in-memory fake of the Cloudflare v4 DNS API for
+// tests, in the spirit of net/http/httptest.
+package cftest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+const (
+	DefaultToken   = "test-token"
+	DefaultTokenID = "0123456789abcdef0123456789abcdef"
+)
+
+// DefaultPermissions are the zone permissions of a token allowed to edit DNS.
+var DefaultPermissions = []string{"#dns_records:edit", "#dns_records:read", "#zone:read"}
+
+// Server fakes the zones, DNS records and token verify endpoints. Records
+// are stored the way Cloudflare returns them: FQDN names, structured data
+// rendered into content, and MX/SRV priorities in a separate field.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	token       string
+	tokenStatus string
+	pageSize    int
+	zones       map[string]*cloudflare.Zone
+	records     map[string][]cloudflare.DNSRecord
+	throttle    int
+	requests    []string
+	nextID      int
+}
+
+// WithToken sets the token the server accepts, DefaultToken by default.
+func WithToken(token string) func(*Server) {
+	return func(s *Server) { s.token = token }
+}
+
+// WithTokenStatus sets the status reported by the token verify endpoint,
+// e.g. "disabled" or "expired".
+func WithTokenStatus(status string) func(*Server) {
+	return func(s *Server) { s.tokenStatus = status }
+}
+
+// WithPageSize caps the page size of list responses, so that small record
+// sets already span several pages.
+func WithPageSize(size int) func(*Server) {
+	return func(s *Server) { s.pageSize = size }
+}
+
+// WithZone adds a zone with the default permissions.
+func WithZone(id, name string) func(*Server) {
+	return func(s *Server) { s.AddZone(id, name, DefaultPermissions...) }
+}
+
+func NewServer(options ...func(*Server)) *Server {
+	s := &Server{
+		token:       DefaultToken,
+		tokenStatus: "active",
+		pageSize:    100,
+		zones:       map[string]*cloudflare.Zone{},
+		records:     map[string][]cloudflare.DNSRecord{},
+	}
+
+	for _, fn := range options {
+		fn(s)
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// AddZone adds a zone on which the token has the given permissions.
+func (s *Server) AddZone(id, name string, permissions ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.zones[id] = &cloudflare.Zone{ID: id, Name: name, Status: "active", Permissions: permissions}
+}
+
+// AddRecord stores rec in the zone as is, assigning an ID if it has none.
+func (s *Server) AddRecord(zoneID string, rec cloudflare.DNSRecord) cloudflare.DNSRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec.ID == "" {
+		rec.ID = s.newID()
+	}
+	s.records[zoneID] = append(s.records[zoneID], rec)
+	return rec
+}
+
+// Records returns the records of a zone, optionally only those of the given
+// type, sorted by name, type and content.
+func (s *Server) Records(zoneID string, rtype ...string) []cloudflare.DNSRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []cloudflare.DNSRecord
+	for _, r := range s.records[zoneID] {
+		if len(rtype) == 0 || r.Type == rtype[0] {
+			res = append(res, r)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Content < b.Content
+	})
+
+	return res
+}
+
+// Throttle answers the next n requests with 429 Too Many Requests.
+func (s *Server) Throttle(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.throttle = n
+}
+
+// Requests returns the "METHOD /path" of every request received so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// Mutations counts the requests that changed records.
+func (s *Server) Mutations() int {
+	n := 0
+	for _, r := range s.Requests() {
+		if !strings.HasPrefix(r, http.MethodGet+" ") {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("%032x", s.nextID)
+}
+
+type envelope struct {
+	Success    bool                      `json:"success"`
+	Errors     []cloudflare.ResponseInfo `json:"errors"`
+	Messages   []cloudflare.ResponseInfo `json:"messages"`
+	Result     any                       `json:"result"`
+	ResultInfo *cloudflare.ResultInfo    `json:"result_info,omitempty"`
+}
+
+func writeResult(w http.ResponseWriter, result any, info *cloudflare.ResultInfo) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(envelope{
+		Success:    true,
+		Errors:     []cloudflare.ResponseInfo{},
+		Messages:   []cloudflare.ResponseInfo{},
+		Result:     result,
+		ResultInfo: info,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(envelope{
+		Errors:   []cloudflare.ResponseInfo{{Code: code, Message: message}},
+		Messages: []cloudflare.ResponseInfo{},
+	})
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	if s.throttle > 0 {
+		s.throttle--
+		w.Header().Set("Retry-After", "0")
+		writeError(w, http.StatusTooManyRequests, 971, "Please wait and consider throttling your request speed")
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+s.token {
+		writeError(w, http.StatusUnauthorized, 1000, "Invalid API Token")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 3 && parts[0] == "user" && parts[1] == "tokens" && parts[2] == "verify":
+		writeResult(w, map[string]any{"id": DefaultTokenID, "status": s.tokenStatus}, nil)
+	case len(parts) == 3 && parts[0] == "user" && parts[1] == "tokens":
+		writeError(w, http.StatusForbidden, 9109, "Unauthorized to access requested resource")
+	case len(parts) == 1 && parts[0] == "zones" && r.Method == http.MethodGet:
+		s.listZones(w, r)
+	case len(parts) >= 2 && parts[0] == "zones":
+		zone, ok := s.zones[parts[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, 7003, "Could not route to /zones/"+parts[1]+", perhaps your object identifier is invalid?")
+			return
+		}
+
+		switch {
+		case len(parts) == 2 && r.Method == http.MethodGet:
+			writeResult(w, zone, nil)
+		case len(parts) == 3 && parts[2] == "dns_records":
+			s.serveRecords(w, r, zone)
+		case len(parts) == 4 && parts[2] == "dns_records":
+			s.serveRecord(w, r, zone, parts[3])
+		default:
+			writeError(w, http.StatusNotFound, 7000, "No route for that URI")
+		}
+	default:
+		writeError(w, http.StatusNotFound, 7000, "No route for that URI")
+	}
+}
+
+func (s *Server) listZones(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+
+	var zones []any
+	for _, z := range s.zones {
+		if name == "" || strings.EqualFold(z.Name, name) {
+			zones = append(zones, z)
+		}
+	}
+
+	s.writePage(w, r, zones)
+}
+
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, items []any) {
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 || perPage > s.pageSize {
+		perPage = s.pageSize
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+
+	writeResult(w, append([]any{}, items[start:end]...), &cloudflare.ResultInfo{
+		Page:       page,
+		PerPage:    perPage,
+		Count:      end - start,
+		Total:      len(items),
+		TotalPages: (len(items) + perPage - 1) / perPage,
+	})
+}
+
+func (s *Server) serveRecords(w http.ResponseWriter, r *http.Request, zone *cloudflare.Zone) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+
+		var matches []any
+		for _, rec := range s.records[zone.ID] {
+			if t := q.Get("type"); t != "" && rec.Type != t {
+				continue
+			}
+			if n := q.Get("name"); n != "" && !strings.EqualFold(rec.Name, fqdn(n, zone.Name)) {
+				continue
+			}
+			if c := q.Get("content"); c != "" && rec.Content != c {
+				continue
+			}
+			matches = append(matches, rec)
+		}
+
+		s.writePage(w, r, matches)
+	case http.MethodPost:
+		var params cloudflare.CreateDNSRecordParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeError(w, http.StatusBadRequest, 9207, "Request body is invalid: "+err.Error())
+			return
+		}
+
+		rec := cloudflare.DNSRecord{
+			ID:       s.newID(),
+			Type:     params.Type,
+			Name:     fqdn(params.Name, zone.Name),
+			Content:  params.Content,
+			Priority: params.Priority,
+			TTL:      params.TTL,
+			Data:     params.Data,
+		}
+		if rec.TTL == 0 {
+			rec.TTL = 1
+		}
+
+		if code, msg := s.validate(zone, &rec); code != 0 {
+			writeError(w, http.StatusBadRequest, code, msg)
+			return
+		}
+
+		rec.CreatedOn = time.Now().UTC()
+		rec.ModifiedOn = rec.CreatedOn
+		s.records[zone.ID] = append(s.records[zone.ID], rec)
+		writeResult(w, rec, nil)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, 10000, "Method not allowed")
+	}
+}
+
+func (s *Server) serveRecord(w http.ResponseWriter, r *http.Request, zone *cloudflare.Zone, id string) {
+	records := s.records[zone.ID]
+
+	idx := -1
+	for i, rec := range records {
+		if rec.ID == id {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		writeError(w, http.StatusNotFound, 81044, "Record does not exist.")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeResult(w, records[idx], nil)
+	case http.MethodPatch, http.MethodPut:
+		var params cloudflare.UpdateDNSRecordParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeError(w, http.StatusBadRequest, 9207, "Request body is invalid: "+err.Error())
+			return
+		}
+
+		rec := records[idx]
+		if params.Type != "" {
+			rec.Type = params.Type
+		}
+		if params.Name != "" {
+			rec.Name = fqdn(params.Name, zone.Name)
+		}
+		if params.Content != "" || params.Data != nil {
+			rec.Content = params.Content
+			rec.Data = params.Data
+		}
+		if params.Priority != nil {
+			rec.Priority = params.Priority
+		}
+		if params.TTL != 0 {
+			rec.TTL = params.TTL
+		}
+
+		if code, msg := s.validate(zone, &rec); code != 0 {
+			writeError(w, http.StatusBadRequest, code, msg)
+			return
+		}
+
+		rec.ModifiedOn = time.Now().UTC()
+		records[idx] = rec
+		writeResult(w, rec, nil)
+	case http.MethodDelete:
+		s.records[zone.ID] = append(records[:idx:idx], records[idx+1:]...)
+		writeResult(w, map[string]string{"id": id}, nil)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, 10000, "Method not allowed")
+	}
+}
+
+// validate applies the checks of the real API that the client code relies
+// on, and renders structured data into content as Cloudflare does.
+func (s *Server) validate(zone *cloudflare.Zone, rec *cloudflare.DNSRecord) (int, string) {
+	if !strings.EqualFold(rec.Name, zone.Name) && !strings.HasSuffix(strings.ToLower(rec.Name), "."+strings.ToLower(zone.Name)) {
+		return 9005, "Content for " + rec.Type + " record is invalid. Name is not part of the zone."
+	}
+
+	switch rec.Type {
+	case "A", "AAAA", "CNAME", "NS", "TXT":
+		if rec.Content == "" {
+			return 9021, "Invalid or missing content for " + rec.Type + " record"
+		}
+	case "MX":
+		if rec.Content == "" || rec.Priority == nil {
+			return 9101, "MX records require content and a priority"
+		}
+	case "CAA", "SRV", "TLSA", "SSHFP":
+		data, ok := rec.Data.(map[string]any)
+		if !ok {
+			return 9101, rec.Type + " records require data"
+		}
+		content, priority, err := dataContent(rec.Type, data)
+		if err != nil {
+			return 9101, err.Error()
+		}
+		rec.Content = content
+		if priority != nil {
+			rec.Priority = priority
+		}
+	default:
+		return 9004, "Unsupported record type " + rec.Type
+	}
+
+	if rec.Type == "CNAME" {
+		for _, other := range s.records[zone.ID] {
+			if other.ID != rec.ID && strings.EqualFold(other.Name, rec.Name) {
+				return 81053, "An A, AAAA, or CNAME record with that host already exists."
+			}
+		}
+	}
+
+	for _, other := range s.records[zone.ID] {
+		if other.ID != rec.ID && other.Type == rec.Type && strings.EqualFold(other.Name, rec.Name) && other.Content == rec.Content {
+			return 81058, "An identical record already exists."
+		}
+	}
+
+	return 0, ""
+}
+
+func dataContent(rtype string, data map[string]any) (string, *uint16, error) {
+	field := func(name string) (string, error) {
+		v, ok := data[name]
+		if !ok {
+			return "", fmt.Errorf("%s record is missing data.%s", rtype, name)
+		}
+		return fmt.Sprint(v), nil
+	}
+
+	var names []string
+	switch rtype {
+	case "CAA":
+		names = []string{"flags", "tag", "value"}
+	case "SRV":
+		names = []string{"weight", "port", "target"}
+	case "TLSA":
+		names = []string{"usage", "selector", "matching_type", "certificate"}
+	case "SSHFP":
+		names = []string{"algorithm", "type", "fingerprint"}
+	}
+
+	var fields []string
+	for _, name := range names {
+		v, err := field(name)
+		if err != nil {
+			return "", nil, err
+		}
+		if rtype == "CAA" && name == "value" {
+			v = strconv.Quote(v)
+		}
+		fields = append(fields, v)
+	}
+
+	var priority *uint16
+	if rtype == "SRV" {
+		v, err := field("priority")
+		if err != nil {
+			return "", nil, err
+		}
+		p, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid SRV priority %q", v)
+		}
+		prio := uint16(p)
+		priority = &prio
+	}
+
+	return strings.Join(fields, " "), priority, nil
+}
+
+func fqdn(name, zone string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name == "@" || name == "" {
+		return zone
+	}
+	if name == zone || strings.HasSuffix(name, "."+zone) {
+		return name
+	}
+	return name + "." + zone
+}
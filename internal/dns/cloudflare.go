@@ -4,16 +4,50 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cloudflare/cloudflare-go"
 )
 
-var ErrInvalidRecordID = errors.New("invalid or missing record id")
+var (
+	ErrInvalidRecordID = errors.New("invalid or missing record id")
+	ErrInvalidContent  = errors.New("invalid record content")
+)
+
+func init() {
+	Register(Provider{
+		Name:        "cloudflare",
+		Description: "Cloudflare DNS (API v4)",
+		Fields: []ConfigField{
+			{Name: "token", Description: "Token for Cloudflare auth", Required: true},
+			{Name: "zone-name", Description: "Zone name"},
+			{Name: "zone-id", Description: "Zone ID, takes precedence over the zone name"},
+			{Name: "api-url", Description: "Cloudflare API base URL, defaults to https://api.cloudflare.com/client/v4"},
+		},
+		Factory: func(cfg Config) (API, error) {
+			if cfg.Get("zone-name")+cfg.Get("zone-id") == "" {
+				return nil, fmt.Errorf("%w: cloudflare requires %q or %q", ErrMissingConfig, "zone-name", "zone-id")
+			}
+
+			return NewCloudFlareDNS(
+				WithCFToken(cfg.Get("token")),
+				WithCFZoneName(cfg.Get("zone-name")),
+				WithCFZoneID(cfg.Get("zone-id")),
+				WithCFBaseURL(cfg.Get("api-url")),
+			), nil
+		},
+	})
+}
 
 type CloudFlareDNS struct {
-	token    string
-	zoneName string
+	token         string
+	zoneName      string
+	zoneID        string
+	baseURL       string
+	clientOptions []cloudflare.Option
 }
 
 func WithCFToken(token string) func(*CloudFlareDNS) {
@@ -24,6 +58,22 @@ func WithCFZoneName(zoneName string) func(*CloudFlareDNS) {
 	return func(d *CloudFlareDNS) { d.zoneName = zoneName }
 }
 
+func WithCFZoneID(zoneID string) func(*CloudFlareDNS) {
+	return func(d *CloudFlareDNS) { d.zoneID = zoneID }
+}
+
+// WithCFBaseURL points the client at another API endpoint, e.g. a fake
+// server in tests.
+func WithCFBaseURL(baseURL string) func(*CloudFlareDNS) {
+	return func(d *CloudFlareDNS) { d.baseURL = baseURL }
+}
+
+// WithCFClientOptions passes options such as a retry policy or HTTP client
+// through to the cloudflare-go client.
+func WithCFClientOptions(options ...cloudflare.Option) func(*CloudFlareDNS) {
+	return func(d *CloudFlareDNS) { d.clientOptions = append(d.clientOptions, options...) }
+}
+
 func NewCloudFlareDNS(options ...func(*CloudFlareDNS)) *CloudFlareDNS {
 	dns := &CloudFlareDNS{}
 
@@ -35,12 +85,12 @@ func NewCloudFlareDNS(options ...func(*CloudFlareDNS)) *CloudFlareDNS {
 }
 
 func (a *CloudFlareDNS) GetRecords(ctx context.Context, recordName, recordType string) ([]Record, error) {
-	api, err := cfAPI(a.token)
+	api, err := a.client()
 	if err != nil {
 		return nil, err
 	}
 
-	id, err := cfZoneID(api, "", a.zoneName)
+	id, err := cfZoneID(api, a.zoneID, a.zoneName)
 	if err != nil {
 		return nil, err
 	}
@@ -59,12 +109,12 @@ func (a *CloudFlareDNS) GetRecords(ctx context.Context, recordName, recordType s
 }
 
 func (a *CloudFlareDNS) CreateMXRecord(ctx context.Context, mailDomain string, mxHost string, weight int) error {
-	api, err := cfAPI(a.token)
+	api, err := a.client()
 	if err != nil {
 		return err
 	}
 
-	id, err := cfZoneID(api, "", a.zoneName)
+	id, err := cfZoneID(api, a.zoneID, a.zoneName)
 	if err != nil {
 		return err
 	}
@@ -73,12 +123,12 @@ func (a *CloudFlareDNS) CreateMXRecord(ctx context.Context, mailDomain string, m
 }
 
 func (a *CloudFlareDNS) GetRecord(ctx context.Context, id any) (Record, error) {
-	api, err := cfAPI(a.token)
+	api, err := a.client()
 	if err != nil {
 		return nil, err
 	}
 
-	zid, err := cfZoneID(api, "", a.zoneName)
+	zid, err := cfZoneID(api, a.zoneID, a.zoneName)
 	if err != nil {
 		return nil, err
 	}
@@ -92,44 +142,48 @@ func (a *CloudFlareDNS) GetRecord(ctx context.Context, id any) (Record, error) {
 }
 
 func (a *CloudFlareDNS) CreateRecord(ctx context.Context, rec Record) error {
-	api, err := cfAPI(a.token)
+	api, err := a.client()
 	if err != nil {
 		return err
 	}
 
-	id, err := cfZoneID(api, "", a.zoneName)
+	id, err := cfZoneID(api, a.zoneID, a.zoneName)
 	if err != nil {
 		return err
 	}
 
-	return createRecord(ctx, api, id, string(rec.Type()), rec.Name(), rec.Content())
+	if rec.Type() == RecordTypeMX {
+		return createMXRecord(ctx, api, id, rec.Name(), rec.Content(), rec.Priority())
+	}
+
+	return createRecord(ctx, api, id, string(rec.Type()), rec.Name(), rec.Content(), rec.Priority())
 }
 
 func (a *CloudFlareDNS) UpdateRecord(ctx context.Context, rec Record) error {
-	api, err := cfAPI(a.token)
+	api, err := a.client()
 	if err != nil {
 		return err
 	}
 
-	id, err := cfZoneID(api, "", a.zoneName)
+	id, err := cfZoneID(api, a.zoneID, a.zoneName)
 	if err != nil {
 		return err
 	}
 
 	if rid, ok := (rec.ID()).(string); ok {
-		return updateRecord(ctx, api, id, rid, string(rec.Type()), rec.Name(), rec.Content())
+		return updateRecord(ctx, api, id, rid, string(rec.Type()), rec.Name(), rec.Content(), rec.Priority())
 	}
 
 	return fmt.Errorf("%w: %q", ErrInvalidRecordID, rec.ID())
 }
 
 func (a *CloudFlareDNS) DeleteRecord(ctx context.Context, id any) error {
-	api, err := cfAPI(a.token)
+	api, err := a.client()
 	if err != nil {
 		return err
 	}
 
-	zid, err := cfZoneID(api, "", a.zoneName)
+	zid, err := cfZoneID(api, a.zoneID, a.zoneName)
 	if err != nil {
 		return err
 	}
@@ -141,24 +195,151 @@ func (a *CloudFlareDNS) DeleteRecord(ctx context.Context, id any) error {
 	return fmt.Errorf("%w: %q", ErrInvalidRecordID, id)
 }
 
+// CFTokenStatus describes an API token and what it may do on the zone.
+type CFTokenStatus struct {
+	ID        string
+	Status    string
+	ExpiresOn time.Time
+	ZoneID    string
+	ZoneName  string
+	DNSEdit   bool
+}
+
+// cfDNSWrite is the permission group that grants "Zone.DNS: Edit".
+const cfDNSWrite = "4755a26eedb94da69e1066d98aa820be"
+
+// VerifyToken checks the token with the token verify endpoint and looks up
+// whether it may edit DNS records on the zone.
+func (a *CloudFlareDNS) VerifyToken(ctx context.Context) (CFTokenStatus, error) {
+	var status CFTokenStatus
+
+	api, err := a.client()
+	if err != nil {
+		return status, err
+	}
+
+	verified, err := api.VerifyAPIToken(ctx)
+	if err != nil {
+		return status, fmt.Errorf("%w: verifying token: %w", ErrUnauthorized, err)
+	}
+
+	status.ID = verified.ID
+	status.Status = verified.Status
+	status.ExpiresOn = verified.ExpiresOn
+
+	if verified.Status != "active" {
+		return status, fmt.Errorf("%w: token is %s", ErrUnauthorized, verified.Status)
+	}
+
+	if status.ZoneID, err = cfZoneID(api, a.zoneID, a.zoneName); err != nil {
+		return status, fmt.Errorf("%w: token cannot see zone %s: %w", ErrUnauthorized, a.zoneID+a.zoneName, err)
+	}
+
+	zone, err := api.ZoneDetails(ctx, status.ZoneID)
+	if err != nil {
+		return status, fmt.Errorf("%w: reading zone %s: %w", ErrUnauthorized, status.ZoneID, err)
+	}
+
+	status.ZoneName = zone.Name
+	status.DNSEdit = slices.Contains(zone.Permissions, "#dns_records:edit")
+
+	// The zone permissions are not always filled in for API tokens; the
+	// token's own policies are readable when it may read tokens.
+	if !status.DNSEdit {
+		if token, err := api.GetAPIToken(ctx, verified.ID); err == nil {
+			status.DNSEdit = cfTokenCanEditDNS(token, status.ZoneID, zone.Account.ID)
+		}
+	}
+
+	return status, nil
+}
+
+// Verify fails unless the token is active and may edit DNS records on the
+// zone, so that a bad token is caught before any record changes.
+func (a *CloudFlareDNS) Verify(ctx context.Context) error {
+	status, err := a.VerifyToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !status.DNSEdit {
+		return fmt.Errorf("%w: token %s lacks the Zone.DNS Edit permission on %s", ErrUnauthorized, status.ID, status.ZoneName)
+	}
+
+	return nil
+}
+
+// cfTokenCanEditDNS reports whether a policy of token grants DNS Write on
+// the zone, either directly or through the account that owns it. An
+// account-wide grant does not count when the zone's account is unknown.
+func cfTokenCanEditDNS(token cloudflare.APIToken, zoneID, accountID string) bool {
+	for _, p := range token.Policies {
+		if p.Effect != "allow" {
+			continue
+		}
+
+		grants := slices.ContainsFunc(p.PermissionGroups, func(g cloudflare.APITokenPermissionGroups) bool {
+			return g.ID == cfDNSWrite || g.Name == "DNS Write"
+		})
+		if !grants {
+			continue
+		}
+
+		for resource := range p.Resources {
+			switch {
+			case resource == "com.cloudflare.api.account.zone."+zoneID,
+				resource == "com.cloudflare.api.account.zone.*",
+				accountID != "" && resource == "com.cloudflare.api.account."+accountID:
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func cfToRecord(r *cloudflare.DNSRecord) Record {
 	if r == nil {
 		return nil
 	}
 
+	var priority int
+	if r.Priority != nil {
+		priority = int(*r.Priority)
+	}
+
 	return record{
-		id:      r.ID,
-		name:    r.Name,
-		rtype:   r.Type,
-		content: r.Content,
-		ttl:     r.TTL,
+		id:       r.ID,
+		name:     r.Name,
+		rtype:    r.Type,
+		content:  r.Content,
+		priority: priority,
+		ttl:      r.TTL,
 	}
 }
 
-func cfAPI(tok string) (*cloudflare.API, error) {
-	return cloudflare.NewWithAPIToken(
-		tok,
-		cloudflare.UserAgent("cloud-init-helper"))
+func (a *CloudFlareDNS) client() (*cloudflare.API, error) {
+	options := []cloudflare.Option{cloudflare.UserAgent("cloud-init-helper")}
+	if a.baseURL != "" {
+		options = append(options, cloudflare.BaseURL(a.baseURL))
+	}
+
+	return cloudflare.NewWithAPIToken(a.token, append(options, a.clientOptions...)...)
+}
+
+// HasZone reports whether the token can see a zone called name.
+func (a *CloudFlareDNS) HasZone(ctx context.Context, name string) (bool, error) {
+	api, err := a.client()
+	if err != nil {
+		return false, err
+	}
+
+	zones, err := api.ListZonesContext(ctx, cloudflare.WithZoneFilters(name, "", ""))
+	if err != nil {
+		return false, err
+	}
+
+	return len(zones.Result) > 0, nil
 }
 
 func cfZoneID(api *cloudflare.API, zoneID, zoneName string) (string, error) {
@@ -170,10 +351,6 @@ func cfZoneID(api *cloudflare.API, zoneID, zoneName string) (string, error) {
 }
 
 func getRecords(ctx context.Context, api *cloudflare.API, zoneID, recordType, recordName, content string) ([]cloudflare.DNSRecord, error) {
-	var res, records []cloudflare.DNSRecord
-	var info *cloudflare.ResultInfo
-	var err error
-
 	zid := cloudflare.ZoneIdentifier(zoneID)
 	p := cloudflare.ListDNSRecordsParams{
 		Type:    recordType,
@@ -181,19 +358,9 @@ func getRecords(ctx context.Context, api *cloudflare.API, zoneID, recordType, re
 		Content: content,
 	}
 
-	for {
-		if records, info, err = api.ListDNSRecords(ctx, zid, p); err != nil {
-			return res, err
-		}
-
-		res = append(res, records...)
-
-		if info.HasMorePages() {
-			continue
-		}
-
-		return res, nil
-	}
+	// Without an explicit page, the client fetches every page itself.
+	records, _, err := api.ListDNSRecords(ctx, zid, p)
+	return records, err
 }
 
 func createMXRecord(ctx context.Context, api *cloudflare.API, zoneID string, mailDomain string, mxHost string, weight int) error {
@@ -214,30 +381,45 @@ func getRecord(ctx context.Context, api *cloudflare.API, zoneID string, rid stri
 	return api.GetDNSRecord(ctx, zid, rid)
 }
 
-func createRecord(ctx context.Context, api *cloudflare.API, zoneID string, rtype string, name string, content string) error {
+func createRecord(ctx context.Context, api *cloudflare.API, zoneID string, rtype string, name string, content string, priority int) error {
 	zid := cloudflare.ZoneIdentifier(zoneID)
 
 	if rtype == "TXT" {
 		content = ensureQuoted(content)
 	}
 
+	data, err := cfRecordData(rtype, content, priority)
+	if err != nil {
+		return err
+	}
+
 	params := cloudflare.CreateDNSRecordParams{
 		Type:    rtype,
 		Name:    name,
 		Content: content,
 	}
 
-	_, err := api.CreateDNSRecord(ctx, zid, params)
+	if data != nil {
+		params.Content = ""
+		params.Data = data
+	}
+
+	_, err = api.CreateDNSRecord(ctx, zid, params)
 	return err
 }
 
-func updateRecord(ctx context.Context, api *cloudflare.API, zoneID string, rid string, rtype string, name string, content string) error {
+func updateRecord(ctx context.Context, api *cloudflare.API, zoneID string, rid string, rtype string, name string, content string, priority int) error {
 	zid := cloudflare.ZoneIdentifier(zoneID)
 
 	if rtype == "TXT" {
 		content = ensureQuoted(content)
 	}
 
+	data, err := cfRecordData(rtype, content, priority)
+	if err != nil {
+		return err
+	}
+
 	params := cloudflare.UpdateDNSRecordParams{
 		ID:      rid,
 		Type:    rtype,
@@ -245,7 +427,12 @@ func updateRecord(ctx context.Context, api *cloudflare.API, zoneID string, rid s
 		Content: content,
 	}
 
-	_, err := api.UpdateDNSRecord(ctx, zid, params)
+	if data != nil {
+		params.Content = ""
+		params.Data = data
+	}
+
+	_, err = api.UpdateDNSRecord(ctx, zid, params)
 	return err
 }
 
@@ -254,6 +441,86 @@ func deleteRecord(ctx context.Context, api *cloudflare.API, zoneID string, rid s
 	return api.DeleteDNSRecord(ctx, zid, rid)
 }
 
+// cfRecordData converts zone file presentation content into the structured
+// data Cloudflare requires for some record types, or nil for the others.
+func cfRecordData(rtype string, content string, priority int) (any, error) {
+	fields := strings.Fields(content)
+
+	switch RecordType(rtype) {
+	case RecordTypeCAA:
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%w: CAA %q", ErrInvalidContent, content)
+		}
+
+		flags, err := strconv.ParseUint(fields[0], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("%w: CAA flags %q", ErrInvalidContent, fields[0])
+		}
+
+		_, value, _ := strings.Cut(strings.TrimSpace(content), fields[1])
+		return map[string]any{
+			"flags": flags,
+			"tag":   fields[1],
+			"value": unquoteTXT(strings.TrimSpace(value)),
+		}, nil
+	case RecordTypeTLSA:
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("%w: TLSA %q", ErrInvalidContent, content)
+		}
+
+		var nums [3]uint64
+		for i := range nums {
+			n, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("%w: TLSA %q", ErrInvalidContent, content)
+			}
+			nums[i] = n
+		}
+
+		return map[string]any{
+			"usage":         nums[0],
+			"selector":      nums[1],
+			"matching_type": nums[2],
+			"certificate":   fields[3],
+		}, nil
+	case RecordTypeSSHFP:
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%w: SSHFP %q", ErrInvalidContent, content)
+		}
+
+		algorithm, aerr := strconv.ParseUint(fields[0], 10, 8)
+		fptype, terr := strconv.ParseUint(fields[1], 10, 8)
+		if aerr != nil || terr != nil {
+			return nil, fmt.Errorf("%w: SSHFP %q", ErrInvalidContent, content)
+		}
+
+		return map[string]any{
+			"algorithm":   algorithm,
+			"type":        fptype,
+			"fingerprint": fields[2],
+		}, nil
+	case RecordTypeSRV:
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%w: SRV %q", ErrInvalidContent, content)
+		}
+
+		weight, werr := strconv.ParseUint(fields[0], 10, 16)
+		port, perr := strconv.ParseUint(fields[1], 10, 16)
+		if werr != nil || perr != nil {
+			return nil, fmt.Errorf("%w: SRV %q", ErrInvalidContent, content)
+		}
+
+		return map[string]any{
+			"priority": priority,
+			"weight":   weight,
+			"port":     port,
+			"target":   strings.TrimSuffix(fields[2], "."),
+		}, nil
+	}
+
+	return nil, nil
+}
+
 func ensureQuoted(s string) string {
 	if len(s) > 0 {
 		if s[0] != '"' {
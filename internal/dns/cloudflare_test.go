@@ -0,0 +1,318 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/dns/cftest"
+)
+
+const testZoneID = "023e105f4ecef8ad9ca31a8372d0c353"
+
+func newTestCFServer(t *testing.T, options ...func(*cftest.Server)) *cftest.Server {
+	t.Helper()
+
+	server := cftest.NewServer(append([]func(*cftest.Server){cftest.WithZone(testZoneID, "example.com")}, options...)...)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestCloudFlareDNS(server *cftest.Server, options ...func(*CloudFlareDNS)) *CloudFlareDNS {
+	return NewCloudFlareDNS(append([]func(*CloudFlareDNS){
+		WithCFToken(cftest.DefaultToken),
+		WithCFZoneName("example.com"),
+		WithCFBaseURL(server.URL),
+		WithCFClientOptions(cloudflare.UsingRateLimit(1000), cloudflare.UsingRetryPolicy(2, 0, 0)),
+	}, options...)...)
+}
+
+func TestCFRecordData(t *testing.T) {
+	data, err := cfRecordData("CAA", `0 issue "letsencrypt.org; validationmethods=dns-01"`, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	m := data.(map[string]any)
+	if m["flags"] != uint64(0) || m["tag"] != "issue" || m["value"] != "letsencrypt.org; validationmethods=dns-01" {
+		t.Errorf("Unexpected CAA data: %v", m)
+	}
+
+	if data, _ = cfRecordData("A", "192.0.2.1", 0); data != nil {
+		t.Errorf("Expected no data for A records, got %v", data)
+	}
+}
+
+func TestCFRecordData_SRV(t *testing.T) {
+	data, err := cfRecordData("SRV", "1 587 mx1.example.com.", 5)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	m := data.(map[string]any)
+	if m["priority"] != 5 || m["weight"] != uint64(1) || m["port"] != uint64(587) || m["target"] != "mx1.example.com" {
+		t.Errorf("Unexpected SRV data: %v", m)
+	}
+
+	if _, err = cfRecordData("SRV", "587 mx1.example.com", 0); err == nil {
+		t.Error("Expected error for malformed SRV content")
+	}
+}
+
+func TestCFTokenCanEditDNS(t *testing.T) {
+	policy := func(effect, group string, resources ...string) cloudflare.APITokenPolicies {
+		p := cloudflare.APITokenPolicies{
+			Effect:           effect,
+			Resources:        map[string]any{},
+			PermissionGroups: []cloudflare.APITokenPermissionGroups{{ID: group}},
+		}
+		for _, r := range resources {
+			p.Resources[r] = "*"
+		}
+		return p
+	}
+
+	const readOnly = "82e64a83756745bbbb1c9c2701bf816b"
+
+	for name, tc := range map[string]struct {
+		policies []cloudflare.APITokenPolicies
+		want     bool
+	}{
+		"zone":          {[]cloudflare.APITokenPolicies{policy("allow", cfDNSWrite, "com.cloudflare.api.account.zone.z1")}, true},
+		"all zones":     {[]cloudflare.APITokenPolicies{policy("allow", cfDNSWrite, "com.cloudflare.api.account.zone.*")}, true},
+		"account":       {[]cloudflare.APITokenPolicies{policy("allow", cfDNSWrite, "com.cloudflare.api.account.a1")}, true},
+		"other account": {[]cloudflare.APITokenPolicies{policy("allow", cfDNSWrite, "com.cloudflare.api.account.a2")}, false},
+		"other zone":    {[]cloudflare.APITokenPolicies{policy("allow", cfDNSWrite, "com.cloudflare.api.account.zone.z2")}, false},
+		"read only":     {[]cloudflare.APITokenPolicies{policy("allow", readOnly, "com.cloudflare.api.account.zone.z1")}, false},
+		"denied":        {[]cloudflare.APITokenPolicies{policy("deny", cfDNSWrite, "com.cloudflare.api.account.zone.z1")}, false},
+		"no policies":   {nil, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := cfTokenCanEditDNS(cloudflare.APIToken{Policies: tc.policies}, "z1", "a1"); got != tc.want {
+				t.Errorf("Expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+
+	account := cloudflare.APIToken{Policies: []cloudflare.APITokenPolicies{policy("allow", cfDNSWrite, "com.cloudflare.api.account.a1")}}
+	if cfTokenCanEditDNS(account, "z1", "") {
+		t.Error("Expected an account-wide grant not to count without the zone's account")
+	}
+}
+
+func TestCloudFlareDNS_CRUD(t *testing.T) {
+	server := newTestCFServer(t)
+	api := newTestCloudFlareDNS(server)
+	ctx := context.Background()
+
+	if err := api.CreateRecord(ctx, NewRecord("www.example.com", RecordTypeA, "192.0.2.1")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	records, err := api.GetRecords(ctx, "www.example.com", "A")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(records) != 1 || records[0].Content() != "192.0.2.1" {
+		t.Fatalf("Unexpected records: %v", records)
+	}
+
+	rec, err := api.GetRecord(ctx, records[0].ID())
+	if err != nil || rec.Name() != "www.example.com" {
+		t.Fatalf("Expected record, got %v, %v", rec, err)
+	}
+
+	if err = api.UpdateRecord(ctx, NewRecordWithID(rec.ID(), rec.Name(), RecordTypeA, "192.0.2.2")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if a := server.Records(testZoneID, "A"); len(a) != 1 || a[0].Content != "192.0.2.2" {
+		t.Errorf("Unexpected A records after update: %+v", a)
+	}
+
+	if err = api.DeleteRecord(ctx, rec.ID()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if a := server.Records(testZoneID); len(a) != 0 {
+		t.Errorf("Expected no records after delete, got %+v", a)
+	}
+
+	if err = api.DeleteRecord(ctx, 42); !errors.Is(err, ErrInvalidRecordID) {
+		t.Errorf("Expected ErrInvalidRecordID, got %v", err)
+	}
+}
+
+func TestCloudFlareDNS_Pagination(t *testing.T) {
+	server := newTestCFServer(t, cftest.WithPageSize(2))
+	api := newTestCloudFlareDNS(server)
+
+	for i := 1; i <= 5; i++ {
+		server.AddRecord(testZoneID, cloudflare.DNSRecord{Type: "A", Name: "www.example.com", Content: fmt.Sprintf("192.0.2.%d", i)})
+	}
+
+	records, err := api.GetRecords(context.Background(), "www.example.com", "A")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(records) != 5 {
+		t.Errorf("Expected all 5 records across pages, got %d", len(records))
+	}
+}
+
+func TestCloudFlareDNS_StructuredRecords(t *testing.T) {
+	server := newTestCFServer(t)
+	api := newTestCloudFlareDNS(server)
+	ctx := context.Background()
+
+	for _, rec := range []Record{
+		NewMXRecord("example.com", "mx1.example.com", 20),
+		NewSRVRecord("_imaps._tcp.example.com", 0, 1, 993, "mx1.example.com"),
+		NewRecord("example.com", RecordTypeCAA, `0 issue "letsencrypt.org"`),
+		NewRecord("_25._tcp.mx1.example.com", RecordTypeTLSA, "3 1 1 abcdef"),
+		NewRecord("mx1.example.com", RecordTypeSSHFP, "4 2 abcdef"),
+	} {
+		if err := api.CreateRecord(ctx, rec); err != nil {
+			t.Fatalf("Creating %s: expected no error, got %v", rec.Type(), err)
+		}
+
+		// Equivalent content must be recognized so reruns create nothing.
+		if err := EnsureRecord(ctx, api, rec); err != nil {
+			t.Fatalf("Ensuring %s: expected no error, got %v", rec.Type(), err)
+		}
+	}
+
+	if got := len(server.Records(testZoneID)); got != 5 {
+		t.Errorf("Expected 5 records, got %d", got)
+	}
+
+	mx, err := api.GetRecords(ctx, "example.com", "MX")
+	if err != nil || len(mx) != 1 || mx[0].Priority() != 20 || mx[0].Content() != "mx1.example.com" {
+		t.Errorf("Unexpected MX records: %v, %v", mx, err)
+	}
+}
+
+func TestCloudFlareDNS_Errors(t *testing.T) {
+	server := newTestCFServer(t)
+	ctx := context.Background()
+
+	if _, err := newTestCloudFlareDNS(server, WithCFToken("wrong")).GetRecords(ctx, "example.com", "MX"); err == nil {
+		t.Error("Expected error for an invalid token")
+	}
+
+	if _, err := newTestCloudFlareDNS(server, WithCFZoneName("example.org")).GetRecords(ctx, "example.org", "MX"); err == nil {
+		t.Error("Expected error for an unknown zone")
+	}
+
+	api := newTestCloudFlareDNS(server)
+	if err := api.CreateRecord(ctx, NewRecord("www.example.com", RecordTypeA, "192.0.2.1")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	err := api.CreateRecord(ctx, NewRecord("www.example.com", RecordTypeCNAME, "example.com"))
+
+	var cfErr *cloudflare.RequestError
+	if !errors.As(err, &cfErr) {
+		t.Errorf("Expected request error from the envelope, got %v", err)
+	}
+}
+
+func TestCloudFlareDNS_RateLimited(t *testing.T) {
+	server := newTestCFServer(t)
+	api := newTestCloudFlareDNS(server)
+
+	server.Throttle(2)
+	if _, err := api.GetRecords(context.Background(), "example.com", "MX"); err != nil {
+		t.Errorf("Expected retries to succeed, got %v", err)
+	}
+
+	server.Throttle(10)
+	if _, err := api.GetRecords(context.Background(), "example.com", "MX"); err == nil {
+		t.Error("Expected error once retries are exhausted")
+	}
+}
+
+func TestCloudFlareDNS_Verify(t *testing.T) {
+	ctx := context.Background()
+
+	server := newTestCFServer(t)
+	if err := Verify(ctx, newTestCloudFlareDNS(server)); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	status, err := newTestCloudFlareDNS(server, WithCFZoneID(testZoneID)).VerifyToken(ctx)
+	if err != nil || status.ZoneName != "example.com" || !status.DNSEdit || status.Status != "active" {
+		t.Errorf("Unexpected status %+v, %v", status, err)
+	}
+
+	for name, api := range map[string]*CloudFlareDNS{
+		"wrong token":  newTestCloudFlareDNS(server, WithCFToken("wrong")),
+		"unknown zone": newTestCloudFlareDNS(server, WithCFZoneName("example.org")),
+		"disabled":     newTestCloudFlareDNS(newTestCFServer(t, cftest.WithTokenStatus("disabled"))),
+	} {
+		if err := Verify(ctx, api); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("%s: expected ErrUnauthorized, got %v", name, err)
+		}
+	}
+
+	readOnly := newTestCFServer(t)
+	readOnly.AddZone(testZoneID, "example.com", "#dns_records:read", "#zone:read")
+	if err := Verify(ctx, newTestCloudFlareDNS(readOnly)); !errors.Is(err, ErrUnauthorized) || !strings.Contains(err.Error(), "DNS Edit") {
+		t.Errorf("Expected missing permission error, got %v", err)
+	}
+}
+
+func TestCloudFlareDNS_MailRecords(t *testing.T) {
+	server := newTestCFServer(t)
+	mc := NewMailConfig(WithAPI(newTestCloudFlareDNS(server)))
+
+	options := UpdateMailRecordsParams{
+		Domain:      "example.com",
+		Postmaster:  "postmaster@example.com",
+		DKIM:        "v=DKIM1; k=rsa; p=" + strings.Repeat("A", 300),
+		MXHosts:     map[string]int{"mx1.example.com": 10, "mx2.example.com": 20},
+		CAA:         &CAAParams{Issuers: []string{"letsencrypt.org"}},
+		ClientHost:  "mx1.example.com",
+		Destructive: true,
+	}
+
+	for range 2 {
+		if err := mc.UpdateAllMailRecords(context.Background(), options); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	counts := map[string]int{}
+	for _, rec := range server.Records(testZoneID) {
+		counts[rec.Type]++
+	}
+
+	want := map[string]int{"MX": 2, "TXT": 5, "CAA": 2, "SRV": 5, "CNAME": 2}
+	for rtype, n := range want {
+		if counts[rtype] != n {
+			t.Errorf("Expected %d %s records after two runs, got %d", n, rtype, counts[rtype])
+		}
+	}
+}
+
+func TestCloudFlareDNS_FindZone(t *testing.T) {
+	server := newTestCFServer(t, cftest.WithZone("9a7806061c88ada191ed06f989cc3dac", "other.org"))
+	api := newTestCloudFlareDNS(server)
+	ctx := context.Background()
+
+	for domain, want := range map[string]string{
+		"example.com":     "example.com",
+		"mail.other.org":  "other.org",
+		"a.b.other.org.":  "other.org",
+		"MX1.Example.COM": "example.com",
+	} {
+		if zone, err := FindZone(ctx, api, domain); err != nil || zone != want {
+			t.Errorf("%s: expected zone %s, got %q, %v", domain, want, zone, err)
+		}
+	}
+
+	if _, err := FindZone(ctx, api, "mail.example.net"); !errors.Is(err, ErrZoneNotFound) {
+		t.Errorf("Expected ErrZoneNotFound, got %v", err)
+	}
+}
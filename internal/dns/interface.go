@@ -1,6 +1,17 @@
 package dns
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrRecordNotFound = errors.New("record not found")
+	ErrUnauthorized   = errors.New("credentials not verified for the zone")
+	ErrZoneNotFound   = errors.New("no zone found")
+)
 
 type API interface {
 	GetRecords(ctx context.Context, recordName, recordType string) ([]Record, error)
@@ -11,6 +22,50 @@ type API interface {
 	DeleteRecord(ctx context.Context, rid any) error
 }
 
+// Verifier is implemented by providers that can check their credentials
+// and permissions on the zone without changing anything.
+type Verifier interface {
+	Verify(ctx context.Context) error
+}
+
+// Verify checks the credentials of api when the provider supports it.
+func Verify(ctx context.Context, api API) error {
+	if v, ok := api.(Verifier); ok {
+		return v.Verify(ctx)
+	}
+	return nil
+}
+
+// ZoneFinder is implemented by providers that can tell whether a zone is
+// visible to their credentials.
+type ZoneFinder interface {
+	HasZone(ctx context.Context, name string) (bool, error)
+}
+
+// FindZone returns the closest zone enclosing domain that api can see,
+// walking up one label at a time.
+func FindZone(ctx context.Context, api API, domain string) (string, error) {
+	f, ok := api.(ZoneFinder)
+	if !ok {
+		return "", fmt.Errorf("%w for %s: provider cannot look up zones", ErrZoneNotFound, domain)
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(domain), ".")
+	for strings.Contains(name, ".") {
+		found, err := f.HasZone(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("looking up zone %s: %w", name, err)
+		}
+		if found {
+			return name, nil
+		}
+
+		_, name, _ = strings.Cut(name, ".")
+	}
+
+	return "", fmt.Errorf("%w for %s", ErrZoneNotFound, domain)
+}
+
 type RecordType string
 
 func (r RecordType) String() string { return string(r) }
@@ -18,10 +73,13 @@ func (r RecordType) String() string { return string(r) }
 const (
 	RecordTypeA     = RecordType("A")
 	RecordTypeAAAA  = RecordType("AAAA")
+	RecordTypeCAA   = RecordType("CAA")
 	RecordTypeCNAME = RecordType("CNAME")
 	RecordTypeMX    = RecordType("MX")
 	RecordTypeNS    = RecordType("NS")
 	RecordTypeSRV   = RecordType("SRV")
+	RecordTypeSSHFP = RecordType("SSHFP")
+	RecordTypeTLSA  = RecordType("TLSA")
 	RecordTypeTXT   = RecordType("TXT")
 )
 
@@ -30,6 +88,7 @@ type Record interface {
 	Name() string
 	Type() RecordType
 	Content() string
+	Priority() int
 	TTL() int
 }
 
@@ -41,16 +100,47 @@ func NewRecord(name string, rtype RecordType, content string) Record {
 	}
 }
 
+func NewMXRecord(name string, mxHost string, priority int) Record {
+	return record{
+		name:     name,
+		rtype:    string(RecordTypeMX),
+		content:  mxHost,
+		priority: priority,
+	}
+}
+
+// NewSRVRecord builds an SRV record; the content holds the weight, port and
+// target, the priority is kept separately as for MX records.
+func NewSRVRecord(name string, priority, weight, port int, target string) Record {
+	return record{
+		name:     name,
+		rtype:    string(RecordTypeSRV),
+		content:  fmt.Sprintf("%d %d %s", weight, port, target),
+		priority: priority,
+	}
+}
+
+func NewRecordWithID(id any, name string, rtype RecordType, content string) Record {
+	return record{
+		id:      id,
+		name:    name,
+		rtype:   string(rtype),
+		content: content,
+	}
+}
+
 type record struct {
-	id      any
-	name    string
-	rtype   string
-	content string
-	ttl     int
+	id       any
+	name     string
+	rtype    string
+	content  string
+	priority int
+	ttl      int
 }
 
 func (r record) ID() any          { return r.id }
 func (r record) Name() string     { return r.name }
 func (r record) Type() RecordType { return RecordType(r.rtype) }
 func (r record) Content() string  { return r.content }
+func (r record) Priority() int    { return r.priority }
 func (r record) TTL() int         { return r.ttl }
@@ -2,21 +2,39 @@ package dns
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+var ErrMXTargetIsCNAME = errors.New("mx target is a CNAME")
+
+// DefaultMXPriority is used for MX hosts given without a priority.
+const DefaultMXPriority = 10
+
 type MailConfig struct {
-	api API
+	api      API
+	resolver Resolver
+}
+
+// Resolver looks up the canonical name of MX hosts outside the mail
+// domain, which the API cannot see. *net.Resolver implements it.
+type Resolver interface {
+	LookupCNAME(ctx context.Context, host string) (string, error)
 }
 
 func WithAPI(api API) func(*MailConfig) { return func(c *MailConfig) { c.api = api } }
 
+func WithResolver(r Resolver) func(*MailConfig) { return func(c *MailConfig) { c.resolver = r } }
+
 func NewMailConfig(options ...func(*MailConfig)) *MailConfig {
-	cfg := &MailConfig{}
+	cfg := &MailConfig{resolver: net.DefaultResolver}
 
 	for _, fn := range options {
 		fn(cfg)
@@ -30,10 +48,51 @@ type UpdateMailRecordsParams struct {
 	MXHosts    map[string]int
 	Postmaster string
 	DKIM       string
+	CAA        *CAAParams
+
+	// ClientHost, when set, is the mail server that RFC 6186 SRV records and
+	// the autoconfig/autodiscover host names point mail clients at.
+	ClientHost string
 
 	Destructive bool
 }
 
+// CAAParams restricts which certificate authorities may issue certificates
+// for the mail domain. An iodef contact is derived from the postmaster.
+type CAAParams struct {
+	Issuers           []string
+	WildcardIssuers   []string
+	AccountURI        string
+	ValidationMethods []string
+}
+
+func (p CAAParams) Values(postmaster string) []string {
+	var params []string
+	if p.AccountURI != "" {
+		params = append(params, "accounturi="+p.AccountURI)
+	}
+	if len(p.ValidationMethods) > 0 {
+		params = append(params, "validationmethods="+strings.Join(p.ValidationMethods, ","))
+	}
+
+	value := func(issuer string) string {
+		return strconv.Quote(strings.Join(append([]string{issuer}, params...), "; "))
+	}
+
+	var res []string
+	for _, issuer := range p.Issuers {
+		res = append(res, "0 issue "+value(issuer))
+	}
+	for _, issuer := range p.WildcardIssuers {
+		res = append(res, "0 issuewild "+value(issuer))
+	}
+	if postmaster != "" {
+		res = append(res, "0 iodef "+strconv.Quote("mailto:"+postmaster))
+	}
+
+	return res
+}
+
 func (c *MailConfig) UpdateAllMailRecords(ctx context.Context, options UpdateMailRecordsParams) error {
 	for name, fn := range map[string]func(context.Context, UpdateMailRecordsParams) error{
 		"MX Records":     c.UpdateMXRecords,
@@ -41,6 +100,8 @@ func (c *MailConfig) UpdateAllMailRecords(ctx context.Context, options UpdateMai
 		"DKIM Record":    c.UpdateDKIMRecord,
 		"DMARC Record":   c.UpdateDMARCRecord,
 		"MTS-STS Record": c.UpdateMTSSTSRecord,
+		"CAA Records":    c.UpdateCAARecords,
+		"Client Records": c.UpdateClientRecords,
 	} {
 		if err := fn(ctx, options); err != nil {
 			return fmt.Errorf("updating mail records (%s): %w", name, err)
@@ -49,7 +110,70 @@ func (c *MailConfig) UpdateAllMailRecords(ctx context.Context, options UpdateMai
 	return nil
 }
 
+// ParseMXHosts parses MX hosts given as "host" or "host:priority".
+func ParseMXHosts(specs []string) (map[string]int, error) {
+	hosts := map[string]int{}
+
+	for _, spec := range specs {
+		host, prio, found := strings.Cut(spec, ":")
+		if host == "" {
+			return nil, fmt.Errorf("invalid mx host %q", spec)
+		}
+
+		priority := DefaultMXPriority
+		if found {
+			p, err := strconv.ParseUint(prio, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid priority for mx host %q: %w", spec, err)
+			}
+			priority = int(p)
+		}
+
+		hosts[host] = priority
+	}
+
+	return hosts, nil
+}
+
+// ValidateMXTargets checks that none of the MX hosts is a CNAME, which
+// RFC 2181 forbids and some senders refuse to deliver to. Hosts outside
+// domain are looked up with the resolver; those that cannot be resolved
+// are logged as unverified.
+func (c *MailConfig) ValidateMXTargets(ctx context.Context, domain string, hosts map[string]int) error {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	for host := range hosts {
+		name := strings.TrimSuffix(strings.ToLower(host), ".")
+		if name != domain && !strings.HasSuffix(name, "."+domain) {
+			cname, err := c.resolver.LookupCNAME(ctx, name)
+			if err != nil {
+				log.Printf("unverified MX target %s: %v", host, err)
+				continue
+			}
+
+			if target := strings.TrimSuffix(strings.ToLower(cname), "."); target != name {
+				return fmt.Errorf("%w: %s -> %s", ErrMXTargetIsCNAME, host, target)
+			}
+			continue
+		}
+
+		existing, err := c.api.GetRecords(ctx, host, string(RecordTypeCNAME))
+		if err != nil {
+			return err
+		}
+
+		if len(existing) > 0 {
+			return fmt.Errorf("%w: %s -> %s", ErrMXTargetIsCNAME, host, existing[0].Content())
+		}
+	}
+
+	return nil
+}
+
 func (c *MailConfig) UpdateMXRecords(ctx context.Context, options UpdateMailRecordsParams) error {
+	if err := c.ValidateMXTargets(ctx, options.Domain, options.MXHosts); err != nil {
+		return err
+	}
+
 	if options.Destructive {
 		existing, err := c.api.GetRecords(ctx, options.Domain, "MX")
 		if err != nil {
@@ -63,8 +187,8 @@ func (c *MailConfig) UpdateMXRecords(ctx context.Context, options UpdateMailReco
 		}
 	}
 
-	for host, weight := range options.MXHosts {
-		if err := c.api.CreateMXRecord(ctx, options.Domain, host, weight); err != nil {
+	for host, priority := range options.MXHosts {
+		if err := c.api.CreateMXRecord(ctx, options.Domain, host, priority); err != nil {
 			return err
 		}
 	}
@@ -167,6 +291,84 @@ func (c *MailConfig) UpdateMTSSTSRecord(ctx context.Context, options UpdateMailR
 	return nil
 }
 
+func (c *MailConfig) UpdateCAARecords(ctx context.Context, options UpdateMailRecordsParams) error {
+	if options.CAA == nil {
+		return nil
+	}
+
+	if options.Destructive {
+		existing, err := c.api.GetRecords(ctx, options.Domain, "CAA")
+		if err != nil {
+			return err
+		}
+
+		for _, rec := range existing {
+			if err = c.api.DeleteRecord(ctx, rec.ID()); err != nil {
+				return fmt.Errorf("deleting record: %v: %w", rec.ID(), err)
+			}
+		}
+	}
+
+	for _, value := range options.CAA.Values(options.Postmaster) {
+		if err := c.ensureRecord(ctx, NewRecord(options.Domain, RecordTypeCAA, value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ClientSRVRecords lists the RFC 6186 (and Outlook autodiscover) SRV records
+// for a maddy server with its default ports. All of them target host itself:
+// an SRV target must not be an alias (RFC 2782), so the autodiscover record
+// does not use the autodiscover.<domain> CNAME.
+func ClientSRVRecords(domain, host string) []Record {
+	return []Record{
+		NewSRVRecord("_submission._tcp."+domain, 0, 1, 587, host),
+		NewSRVRecord("_submissions._tcp."+domain, 0, 1, 465, host),
+		NewSRVRecord("_imap._tcp."+domain, 0, 1, 143, host),
+		NewSRVRecord("_imaps._tcp."+domain, 0, 1, 993, host),
+		NewSRVRecord("_autodiscover._tcp."+domain, 0, 1, 443, host),
+	}
+}
+
+func (c *MailConfig) UpdateClientRecords(ctx context.Context, options UpdateMailRecordsParams) error {
+	if options.ClientHost == "" {
+		return nil
+	}
+
+	var records []Record
+	records = append(records, ClientSRVRecords(options.Domain, options.ClientHost)...)
+	for _, prefix := range []string{"autoconfig.", "autodiscover."} {
+		records = append(records, NewRecord(prefix+options.Domain, RecordTypeCNAME, options.ClientHost))
+	}
+
+	for _, rec := range records {
+		if options.Destructive {
+			existing, err := c.api.GetRecords(ctx, rec.Name(), string(rec.Type()))
+			if err != nil {
+				return err
+			}
+
+			for _, e := range existing {
+				if err = c.api.DeleteRecord(ctx, e.ID()); err != nil {
+					return fmt.Errorf("deleting record: %v: %w", e.ID(), err)
+				}
+			}
+		}
+
+		if err := c.ensureRecord(ctx, rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *MailConfig) ensureRecord(ctx context.Context, rec Record) error {
+	return EnsureRecord(ctx, c.api, rec)
+}
+
 func getDKIMRecord(opts UpdateMailRecordsParams) (string, error) {
 	if opts.DKIM != "" {
 		return opts.DKIM, nil
@@ -0,0 +1,79 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"testing"
+)
+
+func TestParseMXHosts(t *testing.T) {
+	hosts, err := ParseMXHosts([]string{"mx1.example.com", "mx2.example.com:20"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := map[string]int{"mx1.example.com": DefaultMXPriority, "mx2.example.com": 20}
+	if !maps.Equal(hosts, want) {
+		t.Errorf("Expected %v, got %v", want, hosts)
+	}
+
+	for _, spec := range []string{":10", "mx1.example.com:high", "mx1.example.com:70000"} {
+		if _, err := ParseMXHosts([]string{spec}); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestUpdateMXRecords_RejectsCNAME(t *testing.T) {
+	fake, server := newFakePDNS(t)
+	api := newTestPowerDNS(server)
+	ctx := context.Background()
+
+	if err := api.CreateRecord(ctx, NewRecord("mail.example.com", RecordTypeCNAME, "mx1.example.com")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	mc := NewMailConfig(WithAPI(api))
+	err := mc.UpdateMXRecords(ctx, UpdateMailRecordsParams{
+		Domain:  "example.com",
+		MXHosts: map[string]int{"mx1.example.com": 10, "mail.example.com": 20},
+	})
+	if !errors.Is(err, ErrMXTargetIsCNAME) {
+		t.Errorf("Expected ErrMXTargetIsCNAME, got %v", err)
+	}
+
+	if _, ok := fake.rrsets["example.com./MX"]; ok {
+		t.Error("Expected no MX records to be created")
+	}
+}
+
+type fakeResolver map[string]string
+
+func (r fakeResolver) LookupCNAME(_ context.Context, host string) (string, error) {
+	if cname, ok := r[host]; ok {
+		return cname, nil
+	}
+	return "", &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestValidateMXTargets_OutOfZone(t *testing.T) {
+	_, server := newFakePDNS(t)
+	api := newTestPowerDNS(server)
+	ctx := context.Background()
+
+	mc := NewMailConfig(WithAPI(api), WithResolver(fakeResolver{
+		"mx.provider.net":    "mx.provider.net.",
+		"alias.provider.net": "mx.provider.net.",
+	}))
+
+	if err := mc.ValidateMXTargets(ctx, "example.com", map[string]int{"mx.provider.net": 10, "gone.provider.net": 20}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	err := mc.ValidateMXTargets(ctx, "example.com", map[string]int{"alias.provider.net": 10})
+	if !errors.Is(err, ErrMXTargetIsCNAME) {
+		t.Errorf("Expected ErrMXTargetIsCNAME, got %v", err)
+	}
+}
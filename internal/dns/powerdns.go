@@ -0,0 +1,538 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPDNSServerID = "localhost"
+	defaultPDNSTTL      = 300
+)
+
+func init() {
+	Register(Provider{
+		Name:        "powerdns",
+		Description: "PowerDNS Authoritative Server HTTP API",
+		Fields: []ConfigField{
+			{Name: "api-url", Description: "PowerDNS API base URL, e.g. http://127.0.0.1:8081", Required: true},
+			{Name: "api-key", Description: "PowerDNS API key", Required: true},
+			{Name: "server-id", Description: "PowerDNS server id (default localhost)"},
+			{Name: "zone-name", Description: "Zone name", Required: true},
+		},
+		Factory: func(cfg Config) (API, error) {
+			return NewPowerDNS(
+				WithPDNSURL(cfg.Get("api-url")),
+				WithPDNSAPIKey(cfg.Get("api-key")),
+				WithPDNSServerID(cfg.Get("server-id")),
+				WithPDNSZoneName(cfg.Get("zone-name")),
+			), nil
+		},
+	})
+}
+
+// PowerDNS implements API on top of the PowerDNS Authoritative HTTP API.
+//
+// PowerDNS manages RRsets rather than individual records, so every record
+// is identified by a PDNSRecordID and each mutation rewrites the whole RRset
+// it belongs to with a single PATCH.
+type PowerDNS struct {
+	baseURL    string
+	apiKey     string
+	serverID   string
+	zoneName   string
+	httpClient *http.Client
+}
+
+func WithPDNSURL(baseURL string) func(*PowerDNS) {
+	return func(d *PowerDNS) { d.baseURL = strings.TrimSuffix(baseURL, "/") }
+}
+
+func WithPDNSAPIKey(apiKey string) func(*PowerDNS) {
+	return func(d *PowerDNS) { d.apiKey = apiKey }
+}
+
+func WithPDNSServerID(serverID string) func(*PowerDNS) {
+	return func(d *PowerDNS) {
+		if serverID != "" {
+			d.serverID = serverID
+		}
+	}
+}
+
+func WithPDNSZoneName(zoneName string) func(*PowerDNS) {
+	return func(d *PowerDNS) { d.zoneName = zoneName }
+}
+
+func WithPDNSHTTPClient(client *http.Client) func(*PowerDNS) {
+	return func(d *PowerDNS) { d.httpClient = client }
+}
+
+func NewPowerDNS(options ...func(*PowerDNS)) *PowerDNS {
+	dns := &PowerDNS{
+		serverID:   defaultPDNSServerID,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	for _, fn := range options {
+		fn(dns)
+	}
+
+	return dns
+}
+
+// PDNSRecordID identifies a single record inside a PowerDNS RRset.
+type PDNSRecordID struct {
+	Name    string
+	Type    RecordType
+	Content string
+}
+
+func (id PDNSRecordID) String() string {
+	return fmt.Sprintf("%s/%s/%s", id.Name, id.Type, id.Content)
+}
+
+// pdnsRecordID accepts either a PDNSRecordID or its string form, as printed
+// by the read command.
+func pdnsRecordID(id any) (PDNSRecordID, bool) {
+	switch v := id.(type) {
+	case PDNSRecordID:
+		return v, true
+	case string:
+		parts := strings.SplitN(v, "/", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return PDNSRecordID{}, false
+		}
+		return PDNSRecordID{Name: parts[0], Type: RecordType(strings.ToUpper(parts[1])), Content: parts[2]}, true
+	}
+	return PDNSRecordID{}, false
+}
+
+type pdnsZone struct {
+	Name   string      `json:"name"`
+	RRsets []pdnsRRset `json:"rrsets"`
+}
+
+type pdnsRRset struct {
+	Name       string       `json:"name"`
+	Type       string       `json:"type"`
+	TTL        int          `json:"ttl,omitempty"`
+	ChangeType string       `json:"changetype,omitempty"`
+	Records    []pdnsRecord `json:"records"`
+}
+
+type pdnsRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type pdnsPatch struct {
+	RRsets []pdnsRRset `json:"rrsets"`
+}
+
+type pdnsError struct {
+	Error string `json:"error"`
+}
+
+// pdnsStatusError is returned for responses outside the 2xx range.
+type pdnsStatusError struct {
+	Method     string
+	URL        string
+	Status     string
+	StatusCode int
+	Message    string
+}
+
+func (e *pdnsStatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("powerdns %s %s: %s: %s", e.Method, e.URL, e.Status, e.Message)
+	}
+	return fmt.Sprintf("powerdns %s %s: %s", e.Method, e.URL, e.Status)
+}
+
+func (a *PowerDNS) GetRecords(ctx context.Context, recordName, recordType string) ([]Record, error) {
+	zone, err := a.getZone(ctx, recordName, recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Record
+	for _, rrset := range zone.RRsets {
+		if recordName != "" && !strings.EqualFold(rrset.Name, pdnsFQDN(recordName)) {
+			continue
+		}
+
+		if recordType != "" && !strings.EqualFold(rrset.Type, recordType) {
+			continue
+		}
+
+		for _, rec := range rrset.Records {
+			if rec.Disabled {
+				continue
+			}
+			res = append(res, pdnsToRecord(rrset, rec))
+		}
+	}
+
+	return res, nil
+}
+
+func (a *PowerDNS) CreateMXRecord(ctx context.Context, mailDomain string, mxHost string, weight int) error {
+	return a.CreateRecord(ctx, NewMXRecord(mailDomain, mxHost, weight))
+}
+
+func (a *PowerDNS) GetRecord(ctx context.Context, id any) (Record, error) {
+	rid, ok := pdnsRecordID(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRecordID, id)
+	}
+
+	records, err := a.GetRecords(ctx, rid.Name, string(rid.Type))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rec := range records {
+		if rec.ID() == rid {
+			return rec, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrRecordNotFound, rid)
+}
+
+func (a *PowerDNS) CreateRecord(ctx context.Context, rec Record) error {
+	rrset, err := a.getRRset(ctx, rec.Name(), rec.Type())
+	if err != nil {
+		return err
+	}
+
+	content := pdnsContent(rec.Type(), rec.Content(), rec.Priority())
+	for _, r := range rrset.Records {
+		if r.Content == content {
+			return nil
+		}
+	}
+
+	rrset.Records = append(rrset.Records, pdnsRecord{Content: content})
+	if rec.TTL() > 0 {
+		rrset.TTL = rec.TTL()
+	}
+
+	return a.replaceRRset(ctx, rrset)
+}
+
+func (a *PowerDNS) UpdateRecord(ctx context.Context, rec Record) error {
+	rid, ok := pdnsRecordID(rec.ID())
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidRecordID, rec.ID())
+	}
+
+	if !strings.EqualFold(pdnsFQDN(rid.Name), pdnsFQDN(rec.Name())) || rid.Type != rec.Type() {
+		if err := a.CreateRecord(ctx, rec); err != nil {
+			return err
+		}
+		return a.DeleteRecord(ctx, rid)
+	}
+
+	rrset, err := a.getRRset(ctx, rid.Name, rid.Type)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, r := range rrset.Records {
+		if r.Content == rid.Content {
+			rrset.Records[i].Content = pdnsContent(rec.Type(), rec.Content(), rec.Priority())
+			found = true
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%w: %s", ErrRecordNotFound, rid)
+	}
+
+	if rec.TTL() > 0 {
+		rrset.TTL = rec.TTL()
+	}
+
+	return a.replaceRRset(ctx, rrset)
+}
+
+func (a *PowerDNS) DeleteRecord(ctx context.Context, id any) error {
+	rid, ok := pdnsRecordID(id)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidRecordID, id)
+	}
+
+	rrset, err := a.getRRset(ctx, rid.Name, rid.Type)
+	if err != nil {
+		return err
+	}
+
+	var keep []pdnsRecord
+	for _, r := range rrset.Records {
+		if r.Content != rid.Content {
+			keep = append(keep, r)
+		}
+	}
+
+	if len(keep) == len(rrset.Records) {
+		return fmt.Errorf("%w: %s", ErrRecordNotFound, rid)
+	}
+
+	if len(keep) == 0 {
+		rrset.ChangeType = "DELETE"
+		rrset.Records = nil
+		return a.patch(ctx, rrset)
+	}
+
+	rrset.Records = keep
+	return a.replaceRRset(ctx, rrset)
+}
+
+// Verify checks that the API key is accepted and the zone exists. Only a
+// rejected API key is reported as ErrUnauthorized.
+func (a *PowerDNS) Verify(ctx context.Context) error {
+	_, err := a.getZone(ctx, a.zoneName, string(RecordTypeNS))
+
+	var se *pdnsStatusError
+	if errors.As(err, &se) && (se.StatusCode == http.StatusUnauthorized || se.StatusCode == http.StatusForbidden) {
+		return fmt.Errorf("%w: %s: %w", ErrUnauthorized, a.zoneName, err)
+	}
+
+	return err
+}
+
+// HasZone reports whether the server has a zone called name.
+func (a *PowerDNS) HasZone(ctx context.Context, name string) (bool, error) {
+	u := fmt.Sprintf("%s/api/v1/servers/%s/zones?zone=%s",
+		a.baseURL, url.PathEscape(a.serverID), url.QueryEscape(pdnsFQDN(name)))
+
+	var zones []pdnsZone
+	if err := a.do(ctx, http.MethodGet, u, nil, &zones); err != nil {
+		return false, err
+	}
+
+	return len(zones) > 0, nil
+}
+
+func (a *PowerDNS) zoneURL() string {
+	return fmt.Sprintf("%s/api/v1/servers/%s/zones/%s",
+		a.baseURL, url.PathEscape(a.serverID), url.PathEscape(pdnsFQDN(a.zoneName)))
+}
+
+func (a *PowerDNS) getZone(ctx context.Context, name string, rtype string) (pdnsZone, error) {
+	var zone pdnsZone
+
+	// Servers that do not support rrset filtering ignore these parameters,
+	// so callers still filter the result themselves.
+	q := url.Values{}
+	if name != "" {
+		q.Set("rrset_name", pdnsFQDN(name))
+		if rtype != "" {
+			q.Set("rrset_type", strings.ToUpper(rtype))
+		}
+	}
+
+	u := a.zoneURL()
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	err := a.do(ctx, http.MethodGet, u, nil, &zone)
+	return zone, err
+}
+
+func (a *PowerDNS) getRRset(ctx context.Context, name string, rtype RecordType) (pdnsRRset, error) {
+	zone, err := a.getZone(ctx, name, string(rtype))
+	if err != nil {
+		return pdnsRRset{}, err
+	}
+
+	for _, rrset := range zone.RRsets {
+		if strings.EqualFold(rrset.Name, pdnsFQDN(name)) && strings.EqualFold(rrset.Type, string(rtype)) {
+			return rrset, nil
+		}
+	}
+
+	return pdnsRRset{Name: pdnsFQDN(name), Type: string(rtype), TTL: defaultPDNSTTL}, nil
+}
+
+func (a *PowerDNS) replaceRRset(ctx context.Context, rrset pdnsRRset) error {
+	rrset.ChangeType = "REPLACE"
+	if rrset.TTL == 0 {
+		rrset.TTL = defaultPDNSTTL
+	}
+	return a.patch(ctx, rrset)
+}
+
+func (a *PowerDNS) patch(ctx context.Context, rrsets ...pdnsRRset) error {
+	return a.do(ctx, http.MethodPatch, a.zoneURL(), pdnsPatch{RRsets: rrsets}, nil)
+}
+
+func (a *PowerDNS) do(ctx context.Context, method, u string, body any, out any) error {
+	var rdr io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		rdr = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, rdr)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("X-API-Key", a.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		se := &pdnsStatusError{Method: method, URL: u, Status: resp.Status, StatusCode: resp.StatusCode}
+
+		var perr pdnsError
+		if json.Unmarshal(buf, &perr) == nil {
+			se.Message = perr.Error
+		}
+		return se
+	}
+
+	if out == nil || len(buf) == 0 {
+		return nil
+	}
+
+	if err = json.Unmarshal(buf, out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
+
+func pdnsToRecord(rrset pdnsRRset, r pdnsRecord) Record {
+	rtype := RecordType(strings.ToUpper(rrset.Type))
+	content := r.Content
+	priority := 0
+
+	if pdnsHasPriority(rtype) {
+		if prio, rest, ok := strings.Cut(content, " "); ok {
+			if p, err := strconv.Atoi(prio); err == nil {
+				priority = p
+				content = rest
+			}
+		}
+	}
+
+	if pdnsHasTarget(rtype) {
+		content = strings.TrimSuffix(content, ".")
+	}
+
+	return record{
+		id:       PDNSRecordID{Name: strings.TrimSuffix(rrset.Name, "."), Type: rtype, Content: r.Content},
+		name:     strings.TrimSuffix(rrset.Name, "."),
+		rtype:    string(rtype),
+		content:  content,
+		priority: priority,
+		ttl:      rrset.TTL,
+	}
+}
+
+// pdnsContent renders record content in the zone file presentation format
+// PowerDNS expects.
+func pdnsContent(rtype RecordType, content string, priority int) string {
+	switch {
+	case rtype == RecordTypeTXT:
+		content = pdnsQuoteTXT(content)
+	case pdnsHasTarget(rtype):
+		content = pdnsFQDN(content)
+	}
+
+	if pdnsHasPriority(rtype) {
+		content = strconv.Itoa(priority) + " " + content
+	}
+
+	return content
+}
+
+func pdnsHasPriority(rtype RecordType) bool {
+	return rtype == RecordTypeMX || rtype == RecordTypeSRV
+}
+
+// pdnsHasTarget reports whether the last field of the content is a domain
+// name that PowerDNS requires to be fully qualified.
+func pdnsHasTarget(rtype RecordType) bool {
+	switch rtype {
+	case RecordTypeCNAME, RecordTypeMX, RecordTypeNS, RecordTypeSRV:
+		return true
+	}
+	return false
+}
+
+func pdnsFQDN(name string) string {
+	if name == "" || strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// pdnsQuoteTXT quotes TXT content, splitting it into 255 byte character
+// strings as required for long values such as DKIM keys.
+func pdnsQuoteTXT(s string) string {
+	if strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return s
+	}
+
+	var parts []string
+	for len(s) > 255 {
+		parts = append(parts, pdnsCharacterString(s[:255]))
+		s = s[255:]
+	}
+	parts = append(parts, pdnsCharacterString(s))
+
+	return strings.Join(parts, " ")
+}
+
+// pdnsCharacterString quotes s in zone file syntax (RFC 1035 section 5.1):
+// quotes and backslashes are escaped, and bytes outside printable ASCII
+// are written as \DDD.
+func pdnsCharacterString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c > 0x7e:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,412 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakePDNS struct {
+	t      *testing.T
+	mu     sync.Mutex
+	rrsets map[string]pdnsRRset
+}
+
+func newFakePDNS(t *testing.T) (*fakePDNS, *httptest.Server) {
+	f := &fakePDNS{t: t, rrsets: map[string]pdnsRRset{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakePDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("X-API-Key") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(pdnsError{Error: "Unauthorized"})
+		return
+	}
+
+	if r.URL.Path == "/api/v1/servers/localhost/zones" {
+		zones := []pdnsZone{}
+		if z := r.URL.Query().Get("zone"); z == "" || z == "example.com." {
+			zones = append(zones, pdnsZone{Name: "example.com."})
+		}
+		_ = json.NewEncoder(w).Encode(zones)
+		return
+	}
+
+	if r.URL.Path != "/api/v1/servers/localhost/zones/example.com." {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(pdnsError{Error: "Could not find domain"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		zone := pdnsZone{Name: "example.com."}
+		for _, rrset := range f.rrsets {
+			if n := r.URL.Query().Get("rrset_name"); n != "" && n != rrset.Name {
+				continue
+			}
+			if t := r.URL.Query().Get("rrset_type"); t != "" && t != rrset.Type {
+				continue
+			}
+			zone.RRsets = append(zone.RRsets, rrset)
+		}
+		_ = json.NewEncoder(w).Encode(zone)
+	case http.MethodPatch:
+		var patch pdnsPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		for _, rrset := range patch.RRsets {
+			if !strings.HasSuffix(rrset.Name, ".") {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_ = json.NewEncoder(w).Encode(pdnsError{Error: "name is not canonical"})
+				return
+			}
+			key := rrset.Name + "/" + rrset.Type
+			switch rrset.ChangeType {
+			case "REPLACE":
+				rrset.ChangeType = ""
+				f.rrsets[key] = rrset
+			case "DELETE":
+				delete(f.rrsets, key)
+			default:
+				f.t.Errorf("Unexpected changetype %q", rrset.ChangeType)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestPowerDNS(server *httptest.Server) *PowerDNS {
+	return NewPowerDNS(
+		WithPDNSURL(server.URL),
+		WithPDNSAPIKey("secret"),
+		WithPDNSZoneName("example.com"),
+		WithPDNSHTTPClient(server.Client()),
+	)
+}
+
+func TestPowerDNS_CreateAndGetRecords(t *testing.T) {
+	fake, server := newFakePDNS(t)
+	api := newTestPowerDNS(server)
+	ctx := context.Background()
+
+	if err := api.CreateMXRecord(ctx, "example.com", "mx1.example.com", 10); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := api.CreateMXRecord(ctx, "example.com", "mx2.example.com", 20); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := api.CreateRecord(ctx, NewRecord("example.com", RecordTypeTXT, "v=spf1 mx ~all")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	mx := fake.rrsets["example.com./MX"]
+	if len(mx.Records) != 2 || mx.Records[0].Content != "10 mx1.example.com." || mx.Records[1].Content != "20 mx2.example.com." {
+		t.Errorf("Unexpected MX rrset: %+v", mx)
+	}
+
+	if txt := fake.rrsets["example.com./TXT"]; len(txt.Records) != 1 || txt.Records[0].Content != `"v=spf1 mx ~all"` {
+		t.Errorf("Unexpected TXT rrset: %+v", txt)
+	}
+
+	records, err := api.GetRecords(ctx, "example.com", "MX")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	if records[0].Content() != "mx1.example.com" || records[0].Priority() != 10 || records[0].Name() != "example.com" {
+		t.Errorf("Unexpected record: %+v", records[0])
+	}
+
+	rec, err := api.GetRecord(ctx, records[1].ID())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Content() != "mx2.example.com" || rec.Priority() != 20 {
+		t.Errorf("Unexpected record: %+v", rec)
+	}
+}
+
+func TestPowerDNS_TXTEscaping(t *testing.T) {
+	fake, server := newFakePDNS(t)
+	api := newTestPowerDNS(server)
+
+	if err := api.CreateRecord(context.Background(), NewRecord("example.com", RecordTypeTXT, "café \"bar\" a\\b\x00")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := `"caf\195\169 \"bar\" a\\b\000"`
+	if txt := fake.rrsets["example.com./TXT"]; len(txt.Records) != 1 || txt.Records[0].Content != want {
+		t.Errorf("Expected %s, got %+v", want, txt)
+	}
+}
+
+// Content written with \DDD escapes matches the unescaped value, so it is
+// not rewritten on every run.
+func TestPowerDNS_TXTRoundTrip(t *testing.T) {
+	_, server := newFakePDNS(t)
+	api := newTestPowerDNS(server)
+	ctx := context.Background()
+
+	const value = "v=spf1 include:café.example ~all"
+
+	if err := SetRecords(ctx, api, "example.com", RecordTypeTXT, value); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := VerifyRecords(ctx, api, "example.com", RecordTypeTXT, value); err != nil {
+		t.Errorf("Expected the escaped record to match, got %v", err)
+	}
+}
+
+func TestPowerDNS_FindZone(t *testing.T) {
+	_, server := newFakePDNS(t)
+	api := newTestPowerDNS(server)
+	ctx := context.Background()
+
+	if zone, err := FindZone(ctx, api, "mail.example.com"); err != nil || zone != "example.com" {
+		t.Errorf("Expected example.com, got %q, %v", zone, err)
+	}
+
+	if _, err := FindZone(ctx, api, "mail.other.org"); !errors.Is(err, ErrZoneNotFound) {
+		t.Errorf("Expected ErrZoneNotFound, got %v", err)
+	}
+}
+
+func TestPowerDNS_UpdateAndDeleteRecord(t *testing.T) {
+	fake, server := newFakePDNS(t)
+	api := newTestPowerDNS(server)
+	ctx := context.Background()
+
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+		if err := api.CreateRecord(ctx, NewRecord("www.example.com", RecordTypeA, ip)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	records, err := api.GetRecords(ctx, "www.example.com", "A")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	update := NewRecordWithID(records[0].ID(), records[0].Name(), records[0].Type(), "192.0.2.10")
+	if err = api.UpdateRecord(ctx, update); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if a := fake.rrsets["www.example.com./A"]; len(a.Records) != 2 || a.Records[0].Content != "192.0.2.10" {
+		t.Errorf("Unexpected A rrset: %+v", a)
+	}
+
+	if err = api.DeleteRecord(ctx, records[1].ID()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if a := fake.rrsets["www.example.com./A"]; len(a.Records) != 1 {
+		t.Errorf("Expected 1 record left, got %+v", a)
+	}
+
+	records, err = api.GetRecords(ctx, "www.example.com", "A")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err = api.DeleteRecord(ctx, "www.example.com/A/192.0.2.10"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, ok := fake.rrsets["www.example.com./A"]; ok {
+		t.Error("Expected rrset to be deleted")
+	}
+}
+
+func TestPowerDNS_MailRecords(t *testing.T) {
+	fake, server := newFakePDNS(t)
+	mc := NewMailConfig(WithAPI(newTestPowerDNS(server)))
+
+	dkim := "v=DKIM1; k=rsa; p=" + strings.Repeat("A", 300)
+	options := UpdateMailRecordsParams{
+		Domain:      "example.com",
+		Postmaster:  "postmaster@example.com",
+		DKIM:        dkim,
+		MXHosts:     map[string]int{"mx1.example.com": 10},
+		Destructive: true,
+	}
+
+	if err := mc.UpdateAllMailRecords(context.Background(), options); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, key := range []string{
+		"example.com./MX",
+		"example.com./TXT",
+		"default._domainkey.example.com./TXT",
+		"_dmarc.example.com./TXT",
+		"_mta-sts.example.com./TXT",
+		"_smtp._tls.example.com./TXT",
+	} {
+		if _, ok := fake.rrsets[key]; !ok {
+			t.Errorf("Expected rrset %s", key)
+		}
+	}
+
+	dk := fake.rrsets["default._domainkey.example.com./TXT"].Records[0].Content
+	if !strings.HasPrefix(dk, `"v=DKIM1`) || !strings.Contains(dk, `" "`) {
+		t.Errorf("Expected long DKIM value to be split into quoted strings, got %s", dk)
+	}
+
+	if err := mc.UpdateAllMailRecords(context.Background(), options); err != nil {
+		t.Fatalf("Expected second destructive run to succeed, got %v", err)
+	}
+
+	if mx := fake.rrsets["example.com./MX"]; len(mx.Records) != 1 {
+		t.Errorf("Expected a single MX record after rerun, got %+v", mx)
+	}
+}
+
+func TestPowerDNS_Errors(t *testing.T) {
+	_, server := newFakePDNS(t)
+	api := NewPowerDNS(
+		WithPDNSURL(server.URL),
+		WithPDNSAPIKey("wrong"),
+		WithPDNSZoneName("example.com"),
+	)
+
+	_, err := api.GetRecords(context.Background(), "example.com", "MX")
+	if err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("Expected Unauthorized error, got %v", err)
+	}
+
+	if _, err = api.GetRecord(context.Background(), "not-a-pdns-id"); err == nil {
+		t.Error("Expected invalid record id error")
+	}
+}
+
+func TestPowerDNS_CAARecords(t *testing.T) {
+	fake, server := newFakePDNS(t)
+	mc := NewMailConfig(WithAPI(newTestPowerDNS(server)))
+
+	options := UpdateMailRecordsParams{
+		Domain:     "example.com",
+		Postmaster: "postmaster@example.com",
+		CAA: &CAAParams{
+			Issuers:           []string{"letsencrypt.org"},
+			AccountURI:        "https://acme-v02.api.letsencrypt.org/acme/acct/1234",
+			ValidationMethods: []string{"http-01", "dns-01"},
+		},
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := mc.UpdateCAARecords(ctx, options); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	caa := fake.rrsets["example.com./CAA"]
+	want := []string{
+		`0 issue "letsencrypt.org; accounturi=https://acme-v02.api.letsencrypt.org/acme/acct/1234; validationmethods=http-01,dns-01"`,
+		`0 iodef "mailto:postmaster@example.com"`,
+	}
+
+	if len(caa.Records) != len(want) {
+		t.Fatalf("Expected %d CAA records, got %+v", len(want), caa)
+	}
+
+	for i, w := range want {
+		if caa.Records[i].Content != w {
+			t.Errorf("Expected %s, got %s", w, caa.Records[i].Content)
+		}
+	}
+
+	options.CAA = &CAAParams{Issuers: []string{"letsencrypt.org"}, WildcardIssuers: []string{";"}}
+	options.Destructive = true
+	if err := mc.UpdateCAARecords(ctx, options); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if caa = fake.rrsets["example.com./CAA"]; len(caa.Records) != 3 || caa.Records[1].Content != `0 issuewild ";"` {
+		t.Errorf("Unexpected CAA rrset after destructive update: %+v", caa)
+	}
+}
+
+func TestPowerDNS_ClientRecords(t *testing.T) {
+	fake, server := newFakePDNS(t)
+	mc := NewMailConfig(WithAPI(newTestPowerDNS(server)))
+
+	options := UpdateMailRecordsParams{
+		Domain:     "example.com",
+		ClientHost: "mx1.example.com",
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := mc.UpdateClientRecords(ctx, options); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	for key, want := range map[string]string{
+		"_submission._tcp.example.com./SRV":   "0 1 587 mx1.example.com.",
+		"_submissions._tcp.example.com./SRV":  "0 1 465 mx1.example.com.",
+		"_imap._tcp.example.com./SRV":         "0 1 143 mx1.example.com.",
+		"_imaps._tcp.example.com./SRV":        "0 1 993 mx1.example.com.",
+		"_autodiscover._tcp.example.com./SRV": "0 1 443 mx1.example.com.",
+		"autoconfig.example.com./CNAME":       "mx1.example.com.",
+		"autodiscover.example.com./CNAME":     "mx1.example.com.",
+	} {
+		rrset := fake.rrsets[key]
+		if len(rrset.Records) != 1 || rrset.Records[0].Content != want {
+			t.Errorf("Expected %s to be %q, got %+v", key, want, rrset)
+		}
+	}
+}
+
+func TestPowerDNS_Verify(t *testing.T) {
+	_, server := newFakePDNS(t)
+
+	if err := Verify(context.Background(), newTestPowerDNS(server)); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	api := newTestPowerDNS(server)
+	api.apiKey = "wrong"
+	if err := Verify(context.Background(), api); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+
+	// Server errors and unreachable servers are not credential problems.
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "backend down"}`, http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	if err := Verify(context.Background(), newTestPowerDNS(failing)); err == nil || errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected a server error, got %v", err)
+	}
+
+	failing.Close()
+	if err := Verify(context.Background(), newTestPowerDNS(failing)); err == nil || errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected a connection error, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown dns provider")
+	ErrMissingConfig   = errors.New("missing dns provider configuration")
+)
+
+// Config holds provider configuration values keyed by ConfigField name.
+type Config map[string]string
+
+func (c Config) Get(key string) string { return c[key] }
+
+// ConfigField describes a single configuration value a provider accepts.
+type ConfigField struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+type Factory func(Config) (API, error)
+
+// Provider is a registered DNS backend.
+type Provider struct {
+	Name        string
+	Description string
+	Fields      []ConfigField
+	Factory     Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Provider{}
+)
+
+// Register makes a provider available by name. It panics if the name is
+// empty, has no factory, or is already registered.
+func Register(p Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if p.Name == "" || p.Factory == nil {
+		panic("dns: Register called with incomplete provider")
+	}
+
+	if _, dup := registry[p.Name]; dup {
+		panic("dns: Register called twice for provider " + p.Name)
+	}
+
+	registry[p.Name] = p
+}
+
+func LookupProvider(name string) (Provider, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Providers returns the registered providers sorted by name.
+func Providers() []Provider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var res []Provider
+	for _, p := range registry {
+		res = append(res, p)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res
+}
+
+// New builds an API for the named provider after checking required fields.
+func New(name string, cfg Config) (API, error) {
+	p, ok := LookupProvider(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+
+	for _, f := range p.Fields {
+		if f.Required && cfg.Get(f.Name) == "" {
+			return nil, fmt.Errorf("%w: %s requires %q", ErrMissingConfig, name, f.Name)
+		}
+	}
+
+	return p.Factory(cfg)
+}
@@ -0,0 +1,62 @@
+package dns
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegistry_Cloudflare(t *testing.T) {
+	p, ok := LookupProvider("cloudflare")
+	if !ok {
+		t.Fatal("Expected cloudflare provider to be registered")
+	}
+
+	if len(p.Fields) == 0 {
+		t.Error("Expected cloudflare provider to describe its configuration")
+	}
+
+	api, err := New("cloudflare", Config{"token": "tok", "zone-name": "example.com"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, ok := api.(*CloudFlareDNS); !ok {
+		t.Errorf("Expected *CloudFlareDNS, got %T", api)
+	}
+}
+
+func TestRegistry_MissingConfig(t *testing.T) {
+	if _, err := New("cloudflare", Config{"zone-name": "example.com"}); !errors.Is(err, ErrMissingConfig) {
+		t.Errorf("Expected ErrMissingConfig for missing token, got %v", err)
+	}
+
+	if _, err := New("cloudflare", Config{"token": "tok"}); !errors.Is(err, ErrMissingConfig) {
+		t.Errorf("Expected ErrMissingConfig for missing zone, got %v", err)
+	}
+}
+
+func TestRegistry_UnknownProvider(t *testing.T) {
+	if _, err := New("no-such-provider", Config{}); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("Expected ErrUnknownProvider, got %v", err)
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	Register(Provider{
+		Name:    "test-provider",
+		Fields:  []ConfigField{{Name: "endpoint", Required: true}},
+		Factory: func(cfg Config) (API, error) { return NewCloudFlareDNS(), nil },
+	})
+
+	if _, err := New("test-provider", Config{"endpoint": "x"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected duplicate registration to panic")
+		}
+	}()
+
+	Register(Provider{Name: "test-provider", Factory: func(Config) (API, error) { return nil, nil }})
+}
@@ -0,0 +1,90 @@
+package dns
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const DefaultSSHHostKeyPattern = "/etc/ssh/ssh_host_*_key.pub"
+
+var ErrUnsupportedKey = errors.New("unsupported ssh key type")
+
+// sshfpAlgorithms maps SSH public key types to their SSHFP algorithm
+// numbers (RFC 4255, 6594, 7479, 8709).
+var sshfpAlgorithms = map[string]int{
+	"ssh-rsa":             1,
+	"ssh-dss":             2,
+	"ecdsa-sha2-nistp256": 3,
+	"ecdsa-sha2-nistp384": 3,
+	"ecdsa-sha2-nistp521": 3,
+	"ssh-ed25519":         4,
+	"ssh-ed448":           6,
+}
+
+// SSHFPValue returns the SHA-256 SSHFP record content for a public key in
+// authorized_keys format, as found in ssh_host_*_key.pub.
+func SSHFPValue(pubKey string) (string, error) {
+	fields := strings.Fields(pubKey)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("%w: malformed public key", ErrUnsupportedKey)
+	}
+
+	algorithm, ok := sshfpAlgorithms[fields[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedKey, fields[0])
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", fmt.Errorf("decoding %s key: %w", fields[0], err)
+	}
+
+	sum := sha256.Sum256(blob)
+	return fmt.Sprintf("%d 2 %s", algorithm, hex.EncodeToString(sum[:])), nil
+}
+
+// SSHFPValues computes the SSHFP record content for every host public key
+// matching pattern, which defaults to DefaultSSHHostKeyPattern.
+func SSHFPValues(pattern string) ([]string, error) {
+	if pattern == "" {
+		pattern = DefaultSSHHostKeyPattern
+	}
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("finding host keys: %w", err)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no host keys match %q", pattern)
+	}
+
+	sort.Strings(files)
+
+	var res []string
+	for _, f := range files {
+		buf, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading host key: %w", err)
+		}
+
+		value, err := SSHFPValue(string(buf))
+		if errors.Is(err, ErrUnsupportedKey) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+
+		res = append(res, value)
+	}
+
+	return res, nil
+}
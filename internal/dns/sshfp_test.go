@@ -0,0 +1,64 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testEd25519Key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIFAl1mSyWm/EqtzDDAu/YJEt/V45sxLg/ZsMIZG3U/3G root@vm\n"
+
+func TestSSHFPValue(t *testing.T) {
+	// Expected value from ssh-keygen -r.
+	want := "4 2 b1c3c3a7620423baa7fe5636434b67ab7beedd78dc10d2a9a19f7349ed1dd7c1"
+
+	got, err := SSHFPValue(testEd25519Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := SSHFPValue("ssh-unknown AAAA"); !errors.Is(err, ErrUnsupportedKey) {
+		t.Errorf("expected ErrUnsupportedKey, got %v", err)
+	}
+}
+
+func TestSSHFPValues_ReplacesStale(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ssh_host_ed25519_key.pub"), []byte(testEd25519Key), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := SSHFPValues(filepath.Join(dir, "ssh_host_*_key.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 {
+		t.Fatalf("expected 1 value, got %v", values)
+	}
+
+	_, server := newFakePDNS(t)
+	pdns := newTestPowerDNS(server)
+	ctx := context.Background()
+
+	stale := NewRecord("host.example.com", RecordTypeSSHFP, "4 2 0000000000000000000000000000000000000000000000000000000000000000")
+	if err := pdns.CreateRecord(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetRecords(ctx, pdns, "host.example.com", RecordTypeSSHFP, values...); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := pdns.GetRecords(ctx, "host.example.com", string(RecordTypeSSHFP))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].Content() != values[0] {
+		t.Errorf("unexpected records %v", recs)
+	}
+}
@@ -0,0 +1,195 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// SetRecords makes the records with the given name and type match values:
+// missing values are created and any other records are deleted.
+func SetRecords(ctx context.Context, api API, name string, rtype RecordType, values ...string) error {
+	existing, err := api.GetRecords(ctx, name, string(rtype))
+	if err != nil {
+		return err
+	}
+
+	present := map[string]bool{}
+	for _, rec := range existing {
+		if containsContent(rtype, values, rec.Content()) {
+			present[normalizeContent(rtype, rec.Content())] = true
+			continue
+		}
+
+		if err = api.DeleteRecord(ctx, rec.ID()); err != nil {
+			return fmt.Errorf("deleting record: %v: %w", rec.ID(), err)
+		}
+	}
+
+	for _, value := range values {
+		if present[normalizeContent(rtype, value)] {
+			continue
+		}
+
+		if err = api.CreateRecord(ctx, NewRecord(name, rtype, value)); err != nil {
+			return fmt.Errorf("creating %s record %q: %w", rtype, name, err)
+		}
+		present[normalizeContent(rtype, value)] = true
+	}
+
+	return nil
+}
+
+var ErrRecordMismatch = errors.New("records do not match")
+
+// VerifyRecords checks that the records with the given name and type are
+// exactly values, without changing anything.
+func VerifyRecords(ctx context.Context, api API, name string, rtype RecordType, values ...string) error {
+	existing, err := api.GetRecords(ctx, name, string(rtype))
+	if err != nil {
+		return err
+	}
+
+	var contents []string
+	for _, rec := range existing {
+		contents = append(contents, rec.Content())
+	}
+
+	for _, value := range values {
+		if !containsContent(rtype, contents, value) {
+			return fmt.Errorf("%w: %s %s is missing %s, found %v", ErrRecordMismatch, rtype, name, value, contents)
+		}
+	}
+
+	for _, content := range contents {
+		if !containsContent(rtype, values, content) {
+			return fmt.Errorf("%w: unexpected %s %s %s", ErrRecordMismatch, rtype, name, content)
+		}
+	}
+
+	return nil
+}
+
+// EnsureRecord creates rec unless an equivalent record already exists.
+func EnsureRecord(ctx context.Context, api API, rec Record) error {
+	existing, err := api.GetRecords(ctx, rec.Name(), string(rec.Type()))
+	if err != nil {
+		return err
+	}
+
+	for _, e := range existing {
+		if containsContent(rec.Type(), []string{rec.Content()}, e.Content()) {
+			return nil
+		}
+	}
+
+	return api.CreateRecord(ctx, rec)
+}
+
+// DeleteRecords deletes the records with the given name and type whose
+// content is one of values, or all of them when no values are given.
+func DeleteRecords(ctx context.Context, api API, name string, rtype RecordType, values ...string) error {
+	existing, err := api.GetRecords(ctx, name, string(rtype))
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range existing {
+		if len(values) > 0 && !containsContent(rtype, values, rec.Content()) {
+			continue
+		}
+
+		if err = api.DeleteRecord(ctx, rec.ID()); err != nil {
+			return fmt.Errorf("deleting record: %v: %w", rec.ID(), err)
+		}
+	}
+
+	return nil
+}
+
+func containsContent(rtype RecordType, values []string, content string) bool {
+	content = normalizeContent(rtype, content)
+	for _, v := range values {
+		if normalizeContent(rtype, v) == content {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeContent reduces record content to a form that can be compared
+// across providers, which differ in quoting and name qualification.
+func normalizeContent(rtype RecordType, content string) string {
+	content = strings.TrimSpace(content)
+
+	switch rtype {
+	case RecordTypeA, RecordTypeAAAA:
+		if addr, err := netip.ParseAddr(content); err == nil {
+			return addr.String()
+		}
+	case RecordTypeTXT:
+		return unquoteTXT(content)
+	case RecordTypeCNAME, RecordTypeNS, RecordTypeMX, RecordTypeSRV:
+		return strings.ToLower(strings.TrimSuffix(content, "."))
+	case RecordTypeTLSA, RecordTypeSSHFP:
+		return strings.ToLower(strings.Join(strings.Fields(content), " "))
+	case RecordTypeCAA:
+		if fields := strings.Fields(content); len(fields) >= 3 {
+			_, value, _ := strings.Cut(content, fields[1])
+			return fields[0] + " " + strings.ToLower(fields[1]) + " " + unquoteTXT(strings.TrimSpace(value))
+		}
+	}
+
+	return content
+}
+
+// unquoteTXT joins the character strings of a quoted TXT value, decoding
+// the \X and \DDD escapes of zone file syntax (RFC 1035 section 5.1).
+func unquoteTXT(s string) string {
+	if !strings.HasPrefix(s, `"`) {
+		return s
+	}
+
+	var b strings.Builder
+	in := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && in && i+1 < len(s):
+			if n, ok := decimalEscape(s[i+1:]); ok {
+				b.WriteByte(n)
+				i += 3
+			} else {
+				b.WriteByte(s[i+1])
+				i++
+			}
+		case c == '"':
+			in = !in
+		case in:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// decimalEscape decodes the DDD of a \DDD escape at the start of s.
+func decimalEscape(s string) (byte, bool) {
+	if len(s) < 3 {
+		return 0, false
+	}
+
+	n := 0
+	for _, c := range []byte(s[:3]) {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+
+	if n > 255 {
+		return 0, false
+	}
+	return byte(n), true
+}
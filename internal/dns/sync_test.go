@@ -0,0 +1,104 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSetRecords(t *testing.T) {
+	fake, server := newFakePDNS(t)
+	api := newTestPowerDNS(server)
+	ctx := context.Background()
+
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+		if err := api.CreateRecord(ctx, NewRecord("mx1.example.com", RecordTypeA, ip)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	if err := SetRecords(ctx, api, "mx1.example.com", RecordTypeA, "192.0.2.2", "192.0.2.3"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	a := fake.rrsets["mx1.example.com./A"]
+	if len(a.Records) != 2 || a.Records[0].Content != "192.0.2.2" || a.Records[1].Content != "192.0.2.3" {
+		t.Errorf("Unexpected A rrset: %+v", a)
+	}
+
+	if err := SetRecords(ctx, api, "mail.example.com", RecordTypeCNAME, "mx1.example.com"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := SetRecords(ctx, api, "mail.example.com", RecordTypeCNAME, "MX1.example.com."); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if c := fake.rrsets["mail.example.com./CNAME"]; len(c.Records) != 1 || c.Records[0].Content != "mx1.example.com." {
+		t.Errorf("Unexpected CNAME rrset: %+v", c)
+	}
+}
+
+func TestVerifyRecords(t *testing.T) {
+	_, server := newFakePDNS(t)
+	api := newTestPowerDNS(server)
+	ctx := context.Background()
+
+	if err := SetRecords(ctx, api, "mx1.example.com", RecordTypeA, "192.0.2.1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := VerifyRecords(ctx, api, "mx1.example.com", RecordTypeA, "192.0.2.1"); err != nil {
+		t.Errorf("Expected records to verify, got %v", err)
+	}
+
+	for _, values := range [][]string{{"192.0.2.2"}, {"192.0.2.1", "192.0.2.2"}, nil} {
+		if err := VerifyRecords(ctx, api, "mx1.example.com", RecordTypeA, values...); !errors.Is(err, ErrRecordMismatch) {
+			t.Errorf("Expected ErrRecordMismatch for %v, got %v", values, err)
+		}
+	}
+}
+
+func TestDeleteRecords(t *testing.T) {
+	fake, server := newFakePDNS(t)
+	api := newTestPowerDNS(server)
+	ctx := context.Background()
+
+	if err := SetRecords(ctx, api, "mx1.example.com", RecordTypeAAAA, "2001:db8::1", "2001:db8::2"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := DeleteRecords(ctx, api, "mx1.example.com", RecordTypeAAAA, "2001:0db8:0000::1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if aaaa := fake.rrsets["mx1.example.com./AAAA"]; len(aaaa.Records) != 1 || aaaa.Records[0].Content != "2001:db8::2" {
+		t.Errorf("Unexpected AAAA rrset: %+v", aaaa)
+	}
+
+	if err := DeleteRecords(ctx, api, "mx1.example.com", RecordTypeAAAA); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, ok := fake.rrsets["mx1.example.com./AAAA"]; ok {
+		t.Error("Expected AAAA rrset to be deleted")
+	}
+}
+
+func TestNormalizeContent(t *testing.T) {
+	for _, tc := range []struct {
+		rtype    RecordType
+		in, want string
+	}{
+		{RecordTypeTXT, `"v=spf1 mx ~all"`, "v=spf1 mx ~all"},
+		{RecordTypeTXT, `"v=DKIM1; p=AB" "CD"`, "v=DKIM1; p=ABCD"},
+		{RecordTypeTXT, "v=spf1 mx ~all", "v=spf1 mx ~all"},
+		{RecordTypeTXT, `"caf\195\169 \"bar\" a\\b\000"`, "café \"bar\" a\\b\x00"},
+		{RecordTypeCNAME, "MX1.Example.com.", "mx1.example.com"},
+		{RecordTypeAAAA, "2001:0db8::0001", "2001:db8::1"},
+	} {
+		if got := normalizeContent(tc.rtype, tc.in); got != tc.want {
+			t.Errorf("normalizeContent(%s, %q) = %q, want %q", tc.rtype, tc.in, got, tc.want)
+		}
+	}
+}
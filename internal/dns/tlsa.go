@@ -0,0 +1,114 @@
+package dns
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var ErrNoPublicKey = errors.New("no public key found")
+
+// TLSAName returns the owner name of the TLSA records for a TCP service.
+func TLSAName(host string, port int) string {
+	return "_" + strconv.Itoa(port) + "._tcp." + host
+}
+
+// TLSAValue returns the DANE-EE SPKI SHA-256 (3 1 1) record content for pub.
+func TLSAValue(pub crypto.PublicKey) (string, error) {
+	spki, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("marshalling public key: %w", err)
+	}
+
+	sum := sha256.Sum256(spki)
+	return "3 1 1 " + hex.EncodeToString(sum[:]), nil
+}
+
+// TLSAValueFromPEM computes the 3 1 1 record content from the first
+// certificate, certificate request, public key or private key in data. Keys
+// allow publishing the hash of the next key before a certificate exists.
+func TLSAValueFromPEM(data []byte) (string, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return "", ErrNoPublicKey
+		}
+
+		pub, err := pemPublicKey(block)
+		if err != nil {
+			return "", err
+		}
+
+		if pub != nil {
+			return TLSAValue(pub)
+		}
+	}
+}
+
+func pemPublicKey(block *pem.Block) (crypto.PublicKey, error) {
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate: %w", err)
+		}
+		return cert.PublicKey, nil
+	case "CERTIFICATE REQUEST":
+		csr, err := x509.ParseCertificateRequest(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate request: %w", err)
+		}
+		return csr.PublicKey, nil
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+		return publicKeyOf(key)
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+		return key.Public(), nil
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+		return key.Public(), nil
+	}
+
+	return nil, nil
+}
+
+func publicKeyOf(key any) (crypto.PublicKey, error) {
+	if signer, ok := key.(crypto.Signer); ok {
+		return signer.Public(), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported private key type %T", ErrNoPublicKey, key)
+}
+
+// AddTLSA publishes value alongside the existing TLSA records for name, as
+// the first step of a key rollover.
+func AddTLSA(ctx context.Context, api API, name string, value string) error {
+	return EnsureRecord(ctx, api, NewRecord(name, RecordTypeTLSA, value))
+}
+
+// SetTLSA replaces the TLSA records for name with values, completing a key
+// rollover by dropping the hashes of retired keys.
+func SetTLSA(ctx context.Context, api API, name string, values ...string) error {
+	if len(values) == 0 {
+		return fmt.Errorf("refusing to remove all TLSA records for %s", name)
+	}
+	return SetRecords(ctx, api, name, RecordTypeTLSA, values...)
+}
@@ -0,0 +1,101 @@
+package dns
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestTLSAValueFromPEM(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mx1.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spki, _ := x509.MarshalPKIXPublicKey(key.Public())
+	sum := sha256.Sum256(spki)
+	want := "3 1 1 " + hex.EncodeToString(sum[:])
+
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	ecKey, _ := x509.MarshalECPrivateKey(key)
+
+	for name, block := range map[string]*pem.Block{
+		"certificate": {Type: "CERTIFICATE", Bytes: certDER},
+		"public key":  {Type: "PUBLIC KEY", Bytes: spki},
+		"pkcs8 key":   {Type: "PRIVATE KEY", Bytes: pkcs8},
+		"ec key":      {Type: "EC PRIVATE KEY", Bytes: ecKey},
+	} {
+		got, err := TLSAValueFromPEM(pem.EncodeToMemory(block))
+		if err != nil {
+			t.Errorf("%s: Expected no error, got %v", name, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: Expected %s, got %s", name, want, got)
+		}
+	}
+
+	if _, err = TLSAValueFromPEM([]byte("not pem")); err == nil {
+		t.Error("Expected error for non-PEM input")
+	}
+}
+
+func TestTLSARollover(t *testing.T) {
+	fake, server := newFakePDNS(t)
+	api := newTestPowerDNS(server)
+	ctx := context.Background()
+	name := TLSAName("mx1.example.com", 25)
+
+	if name != "_25._tcp.mx1.example.com" {
+		t.Errorf("Unexpected TLSA name %s", name)
+	}
+
+	current := "3 1 1 aaaa"
+	next := "3 1 1 bbbb"
+
+	if err := SetTLSA(ctx, api, name, current); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := AddTLSA(ctx, api, name, next); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := AddTLSA(ctx, api, name, next); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rrset := fake.rrsets[name+"./TLSA"]; len(rrset.Records) != 2 {
+		t.Fatalf("Expected current and next hashes during rollover, got %+v", rrset)
+	}
+
+	if err := SetTLSA(ctx, api, name, next); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rrset := fake.rrsets[name+"./TLSA"]; len(rrset.Records) != 1 || rrset.Records[0].Content != next {
+		t.Errorf("Expected only the new hash after rollover, got %+v", rrset)
+	}
+
+	if err := SetTLSA(ctx, api, name); err == nil {
+		t.Error("Expected error when removing all TLSA records")
+	}
+}
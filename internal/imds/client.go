@@ -2,119 +2,445 @@ package imds
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
 const (
-	TokenTTLSeconds = 21600
-	defaultTokenURL = "http://169.254.169.254/latest/api/token"
-	defaultMetadataURL = "http://169.254.169.254/latest/meta-data"
-	defaultDynamicURL = "http://169.254.169.254/latest/dynamic"
-	defaultUserDataURL = "http://169.254.169.254/latest/user-data"
+	// EndpointEnv and EndpointModeEnv are the variables the AWS SDKs read to
+	// locate IMDS.
+	EndpointEnv     = "AWS_EC2_METADATA_SERVICE_ENDPOINT"
+	EndpointModeEnv = "AWS_EC2_METADATA_SERVICE_ENDPOINT_MODE"
+
+	EndpointModeIPv4 = "IPv4"
+	EndpointModeIPv6 = "IPv6"
+
+	DefaultEndpointIPv4 = "http://169.254.169.254"
+	DefaultEndpointIPv6 = "http://[fd00:ec2::254]"
+
+	DefaultTokenTTL = 6 * time.Hour
+	DefaultTimeout  = 5 * time.Second
+
+	DefaultMaxRetries    = 3
+	DefaultMinRetryDelay = 100 * time.Millisecond
+	DefaultMaxRetryDelay = 2 * time.Second
 )
 
 var (
-	TokenURL    = defaultTokenURL
-	MetadataURL = defaultMetadataURL
-	DynamicURL  = defaultDynamicURL
-	UserDataURL = defaultUserDataURL
+	ErrNotFound      = errors.New("metadata not found")
+	ErrUnauthorized  = errors.New("metadata request unauthorized")
+	ErrUnavailable   = errors.New("metadata service unavailable")
+	ErrTokenRequired = errors.New("metadata service requires an IMDSv2 token")
+	ErrEndpointMode  = errors.New("invalid metadata service endpoint mode")
 )
 
+// StatusError is returned for responses other than 200 OK. It matches
+// ErrNotFound, ErrUnauthorized or ErrUnavailable by status code.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s failed with status %d", e.Method, e.URL, e.StatusCode)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrUnavailable:
+		// IMDS answers 403 when it is disabled for the instance.
+		return e.StatusCode == http.StatusForbidden || e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+func unavailable(err error) error {
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
+
 type Client struct {
-	httpClient *http.Client
+	endpoint     string
+	endpointMode string
+	err          error
+	httpClient   *http.Client
+	timeout      time.Duration
+	tokenTTL     time.Duration
+	v1Fallback   bool
+
+	maxRetries    int
+	minRetryDelay time.Duration
+	maxRetryDelay time.Duration
+
+	// mu guards the token and the IMDSv1 switch.
+	mu         sync.Mutex
+	tokenGroup singleflight.Group
 	token      string
 	tokenExp   time.Time
+	v1         bool
+}
+
+// WithEndpoint sets the IMDS base URL, e.g. http://169.254.169.254. It
+// takes precedence over the endpoint mode.
+func WithEndpoint(endpoint string) func(*Client) {
+	return func(c *Client) { c.endpoint = strings.TrimSuffix(endpoint, "/") }
 }
 
-func NewClient() *Client {
-	return &Client{
-		httpClient: &http.Client{
-			Timeout: 5 * time.Second,
-		},
+// WithEndpointMode selects the default IPv4 or IPv6 endpoint.
+func WithEndpointMode(mode string) func(*Client) {
+	return func(c *Client) { c.endpointMode = mode }
+}
+
+// WithHTTPClient replaces the HTTP client; WithTimeout does not apply to it.
+func WithHTTPClient(client *http.Client) func(*Client) {
+	return func(c *Client) { c.httpClient = client }
+}
+
+func WithTimeout(timeout time.Duration) func(*Client) {
+	return func(c *Client) { c.timeout = timeout }
+}
+
+// WithV1Fallback makes requests without a token when the IMDSv2 token
+// cannot be fetched, e.g. in containers where the hop limit drops the
+// token response. A token endpoint that is not supported switches the
+// client to IMDSv1; after other failures the next request tries IMDSv2
+// again.
+func WithV1Fallback(enabled bool) func(*Client) {
+	return func(c *Client) { c.v1Fallback = enabled }
+}
+
+// WithRetryPolicy sets how often connection errors and 5xx responses are
+// retried, and the bounds of the exponential backoff between attempts.
+func WithRetryPolicy(maxRetries int, minDelay, maxDelay time.Duration) func(*Client) {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.minRetryDelay = minDelay
+		c.maxRetryDelay = maxDelay
 	}
 }
 
-func (c *Client) getToken(ctx context.Context) error {
-	if c.token != "" && time.Now().Before(c.tokenExp) {
-		return nil
+func WithTokenTTL(ttl time.Duration) func(*Client) {
+	return func(c *Client) { c.tokenTTL = ttl }
+}
+
+// NewClient returns a client for the endpoint given by the options, or by
+// AWS_EC2_METADATA_SERVICE_ENDPOINT and AWS_EC2_METADATA_SERVICE_ENDPOINT_MODE
+// when the options leave it unset. An endpoint mode other than IPv4 or IPv6
+// makes every request fail with ErrEndpointMode.
+func NewClient(options ...func(*Client)) *Client {
+	c := &Client{
+		endpoint:     strings.TrimSuffix(os.Getenv(EndpointEnv), "/"),
+		endpointMode: os.Getenv(EndpointModeEnv),
+		timeout:      DefaultTimeout,
+		tokenTTL:     DefaultTokenTTL,
+
+		maxRetries:    DefaultMaxRetries,
+		minRetryDelay: DefaultMinRetryDelay,
+		maxRetryDelay: DefaultMaxRetryDelay,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	if c.endpoint == "" {
+		switch {
+		case c.endpointMode == "" || strings.EqualFold(c.endpointMode, EndpointModeIPv4):
+			c.endpoint = DefaultEndpointIPv4
+		case strings.EqualFold(c.endpointMode, EndpointModeIPv6):
+			c.endpoint = DefaultEndpointIPv6
+		default:
+			c.endpoint = DefaultEndpointIPv4
+			c.err = fmt.Errorf("%w %q: must be %s or %s", ErrEndpointMode, c.endpointMode, EndpointModeIPv4, EndpointModeIPv6)
+		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "PUT", TokenURL, nil)
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: c.timeout}
+	}
+
+	return c
+}
+
+// Endpoint returns the IMDS base URL the client uses.
+func (c *Client) Endpoint() string {
+	return c.endpoint
+}
+
+func (c *Client) tokenURL() string    { return c.endpoint + "/latest/api/token" }
+func (c *Client) metadataURL() string { return c.endpoint + "/latest/meta-data" }
+func (c *Client) dynamicURL() string  { return c.endpoint + "/latest/dynamic" }
+func (c *Client) userDataURL() string { return c.endpoint + "/latest/user-data" }
+
+// do sends a request, retrying connection errors and 5xx responses with
+// exponential backoff. Other responses than 200 OK are returned as a
+// *StatusError.
+func (c *Client) do(ctx context.Context, method, url string, header map[string]string) ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	delay := c.minRetryDelay
+
+	for attempt := 0; ; attempt++ {
+		body, err := c.doOnce(ctx, method, url, header)
+		if err == nil || attempt >= c.maxRetries || !retryable(err) {
+			return body, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, c.maxRetryDelay)
+	}
+}
+
+func (c *Client) doOnce(ctx context.Context, method, url string, header map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
-		return fmt.Errorf("creating token request: %w", err)
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
-	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", fmt.Sprintf("%d", TokenTTLSeconds))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("getting IMDSv2 token: %w", err)
+		return nil, unavailable(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("token request failed with status %d", resp.StatusCode)
+		return nil, &StatusError{Method: method, URL: req.URL.Path, StatusCode: resp.StatusCode}
 	}
 
-	tokenBytes, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("reading token response: %w", err)
+		return nil, fmt.Errorf("reading response: %w", unavailable(err))
 	}
 
-	c.token = string(tokenBytes)
-	c.tokenExp = time.Now().Add(time.Duration(TokenTTLSeconds) * time.Second)
+	return body, nil
+}
 
-	return nil
+// retryable reports whether err may go away on its own: IMDS not answering
+// yet, or answering with a server error.
+func retryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= http.StatusInternalServerError || se.StatusCode == http.StatusTooManyRequests
+	}
+	return errors.Is(err, ErrUnavailable)
 }
 
-func (c *Client) makeRequest(ctx context.Context, url string) (string, error) {
-	if err := c.getToken(ctx); err != nil {
-		return "", err
+// getToken returns the cached token, fetching a new one when it expired.
+// Concurrent callers share a single token request. It runs detached from
+// the caller that started it, bounded by the client timeout, so a caller
+// giving up does not fail the others; each caller still returns as soon as
+// its own ctx is done.
+func (c *Client) getToken(ctx context.Context) (string, error) {
+	if token, ok := c.cachedToken(); ok {
+		return token, nil
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
+	ch := c.tokenGroup.DoChan("token", func() (any, error) {
+		if token, ok := c.cachedToken(); ok {
+			return token, nil
+		}
+
+		fetchCtx := context.WithoutCancel(ctx)
+		if c.timeout > 0 {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithTimeout(fetchCtx, c.timeout)
+			defer cancel()
+		}
+
+		token, err := c.do(fetchCtx, "PUT", c.tokenURL(), map[string]string{
+			"X-aws-ec2-metadata-token-ttl-seconds": strconv.Itoa(int(c.tokenTTL.Seconds())),
+		})
+		if err != nil {
+			return "", fmt.Errorf("getting IMDSv2 token: %w", err)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.token = string(token)
+		c.tokenExp = time.Now().Add(c.tokenTTL)
+
+		return c.token, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-ch:
+		return r.Val.(string), r.Err
 	}
+}
 
-	req.Header.Set("X-aws-ec2-metadata-token", c.token)
+func (c *Client) cachedToken() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("making request: %w", err)
+	return c.token, c.token != "" && time.Now().Before(c.tokenExp)
+}
+
+// dropToken forgets token unless another request already replaced it.
+func (c *Client) dropToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == token {
+		c.token = ""
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("request failed with status %d", resp.StatusCode)
+func (c *Client) isV1() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.v1
+}
+
+// useV1 reports whether a failed token request should be answered by
+// falling back to IMDSv1, and whether the client should stay on IMDSv1.
+// Only a token endpoint answering 403, 404 or 405 is taken as not
+// supporting IMDSv2; a timeout or connection error falls back for this
+// request alone, and any other status is returned as is.
+func (c *Client) useV1(ctx context.Context, err error) (use, stay bool) {
+	if !c.v1Fallback || ctx.Err() != nil {
+		return false, false
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("reading response: %w", err)
+	var se *StatusError
+	if !errors.As(err, &se) {
+		return true, false
 	}
 
-	return string(body), nil
+	switch se.StatusCode {
+	case http.StatusForbidden, http.StatusNotFound, http.StatusMethodNotAllowed:
+		return true, true
+	}
+	return false, false
+}
+
+func (c *Client) makeRequest(ctx context.Context, url string) (string, error) {
+	var token string
+	if !c.isV1() {
+		var err error
+		if token, err = c.getToken(ctx); err != nil {
+			use, stay := c.useV1(ctx, err)
+			if !use {
+				return "", err
+			}
+
+			if stay {
+				c.mu.Lock()
+				c.v1 = true
+				c.mu.Unlock()
+			}
+		}
+	}
+
+	if token == "" {
+		body, err := c.do(ctx, "GET", url, nil)
+		if errors.Is(err, ErrUnauthorized) {
+			return "", fmt.Errorf("%w: %w", ErrTokenRequired, err)
+		}
+		return string(body), err
+	}
+
+	body, err := c.do(ctx, "GET", url, map[string]string{"X-aws-ec2-metadata-token": token})
+	if errors.Is(err, ErrUnauthorized) {
+		// The token was revoked or IMDS restarted; get a new one once.
+		c.dropToken(token)
+		if token, err = c.getToken(ctx); err != nil {
+			return "", err
+		}
+		body, err = c.do(ctx, "GET", url, map[string]string{"X-aws-ec2-metadata-token": token})
+	}
+
+	return string(body), err
+}
+
+// Result is the outcome of fetching one path in a batch.
+type Result struct {
+	Path  string
+	Value string
+	Err   error
+}
+
+// GetMetadataBatch fetches the metadata paths concurrently and returns the
+// results in the order of paths.
+func (c *Client) GetMetadataBatch(ctx context.Context, paths ...string) []Result {
+	results := make([]Result, len(paths))
+
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			value, err := c.GetMetadata(ctx, path)
+			results[i] = Result{Path: path, Value: value, Err: err}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Wait blocks until IMDS answers a metadata request, or ctx is done.
+func (c *Client) Wait(ctx context.Context) error {
+	delay := c.minRetryDelay
+
+	for {
+		_, err := c.GetInstanceID(ctx)
+		if err == nil || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for IMDS: %w", err)
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, c.maxRetryDelay)
+	}
 }
 
 func (c *Client) GetMetadata(ctx context.Context, path string) (string, error) {
 	path = strings.TrimPrefix(path, "/")
-	url := fmt.Sprintf("%s/%s", MetadataURL, path)
+	url := fmt.Sprintf("%s/%s", c.metadataURL(), path)
 	return c.makeRequest(ctx, url)
 }
 
 func (c *Client) GetDynamic(ctx context.Context, path string) (string, error) {
 	path = strings.TrimPrefix(path, "/")
-	url := fmt.Sprintf("%s/%s", DynamicURL, path)
+	url := fmt.Sprintf("%s/%s", c.dynamicURL(), path)
 	return c.makeRequest(ctx, url)
 }
 
 func (c *Client) GetUserData(ctx context.Context) (string, error) {
-	return c.makeRequest(ctx, UserDataURL)
+	return c.makeRequest(ctx, c.userDataURL())
 }
 
 func (c *Client) GetInstanceID(ctx context.Context) (string, error) {
@@ -133,6 +459,32 @@ func (c *Client) GetPublicIPv4(ctx context.Context) (string, error) {
 	return c.GetMetadata(ctx, "public-ipv4")
 }
 
+func (c *Client) GetMAC(ctx context.Context) (string, error) {
+	return c.GetMetadata(ctx, "mac")
+}
+
+func (c *Client) GetIPv6(ctx context.Context) (string, error) {
+	if ipv6, err := c.GetMetadata(ctx, "ipv6"); err == nil {
+		return ipv6, nil
+	}
+
+	mac, err := c.GetMAC(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	ipv6s, err := c.ListMetadataPaths(ctx, "network/interfaces/macs/"+mac+"/ipv6s")
+	if err != nil {
+		return "", err
+	}
+
+	if len(ipv6s) == 0 {
+		return "", fmt.Errorf("no ipv6 address assigned to %s: %w", mac, ErrNotFound)
+	}
+
+	return ipv6s[0], nil
+}
+
 func (c *Client) GetRegion(ctx context.Context) (string, error) {
 	az, err := c.GetMetadata(ctx, "placement/availability-zone")
 	if err != nil {
@@ -2,9 +2,12 @@ package imds
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -22,15 +25,10 @@ func TestClient_GetToken(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := NewClient()
-	client.httpClient = server.Client()
-
-	originalTokenURL := TokenURL
-	TokenURL = server.URL
-	defer func() { TokenURL = originalTokenURL }()
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
 
 	ctx := context.Background()
-	err := client.getToken(ctx)
+	_, err := client.getToken(ctx)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -71,12 +69,7 @@ func TestClient_MakeRequest(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := NewClient()
-	client.httpClient = server.Client()
-
-	originalTokenURL := TokenURL
-	TokenURL = server.URL + "/token"
-	defer func() { TokenURL = originalTokenURL }()
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
 
 	ctx := context.Background()
 	response, err := client.makeRequest(ctx, server.URL+"/metadata")
@@ -105,7 +98,7 @@ func TestClient_GetMetadata(t *testing.T) {
 			return
 		}
 
-		if r.URL.Path == "/meta-data/instance-id" {
+		if r.URL.Path == "/latest/meta-data/instance-id" {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte("i-1234567890abcdef0"))
 			return
@@ -115,17 +108,7 @@ func TestClient_GetMetadata(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := NewClient()
-	client.httpClient = server.Client()
-
-	originalTokenURL := TokenURL
-	originalMetadataURL := MetadataURL
-	TokenURL = server.URL + "/token"
-	MetadataURL = server.URL + "/meta-data"
-	defer func() {
-		TokenURL = originalTokenURL
-		MetadataURL = originalMetadataURL
-	}()
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
 
 	ctx := context.Background()
 	instanceID, err := client.GetMetadata(ctx, "instance-id")
@@ -146,7 +129,7 @@ func TestClient_GetRegion(t *testing.T) {
 			return
 		}
 
-		if r.URL.Path == "/meta-data/placement/availability-zone" {
+		if r.URL.Path == "/latest/meta-data/placement/availability-zone" {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte("us-west-2a"))
 			return
@@ -156,17 +139,7 @@ func TestClient_GetRegion(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := NewClient()
-	client.httpClient = server.Client()
-
-	originalTokenURL := TokenURL
-	originalMetadataURL := MetadataURL
-	TokenURL = server.URL + "/token"
-	MetadataURL = server.URL + "/meta-data"
-	defer func() {
-		TokenURL = originalTokenURL
-		MetadataURL = originalMetadataURL
-	}()
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
 
 	ctx := context.Background()
 	region, err := client.GetRegion(ctx)
@@ -187,7 +160,7 @@ func TestClient_ListMetadataPaths(t *testing.T) {
 			return
 		}
 
-		if r.URL.Path == "/meta-data/" {
+		if r.URL.Path == "/latest/meta-data/" {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte("instance-id\ninstance-type\nlocal-ipv4\npublic-ipv4\n"))
 			return
@@ -197,17 +170,7 @@ func TestClient_ListMetadataPaths(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := NewClient()
-	client.httpClient = server.Client()
-
-	originalTokenURL := TokenURL
-	originalMetadataURL := MetadataURL
-	TokenURL = server.URL + "/token"
-	MetadataURL = server.URL + "/meta-data"
-	defer func() {
-		TokenURL = originalTokenURL
-		MetadataURL = originalMetadataURL
-	}()
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
 
 	ctx := context.Background()
 	paths, err := client.ListMetadataPaths(ctx, "")
@@ -226,3 +189,420 @@ func TestClient_ListMetadataPaths(t *testing.T) {
 		}
 	}
 }
+
+func TestClient_GetIPv6(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/token") {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("test-token"))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/latest/meta-data/mac":
+			w.Write([]byte("0e:00:00:00:00:01"))
+		case "/latest/meta-data/network/interfaces/macs/0e:00:00:00:00:01/ipv6s":
+			w.Write([]byte("2001:db8::1\n2001:db8::2"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
+
+	ipv6, err := client.GetIPv6(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if ipv6 != "2001:db8::1" {
+		t.Errorf("Expected ipv6 '2001:db8::1', got %s", ipv6)
+	}
+}
+
+func TestNewClient_Endpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		mode     string
+		options  []func(*Client)
+		want     string
+	}{
+		{name: "default", want: DefaultEndpointIPv4},
+		{name: "ipv6 mode", mode: "ipv6", want: DefaultEndpointIPv6},
+		{name: "ipv4 mode", mode: "IPv4", want: DefaultEndpointIPv4},
+		{name: "env endpoint", endpoint: "http://127.0.0.1:1338/", mode: "IPv6", want: "http://127.0.0.1:1338"},
+		{name: "mode option", options: []func(*Client){WithEndpointMode(EndpointModeIPv6)}, want: DefaultEndpointIPv6},
+		{name: "endpoint option", endpoint: "http://127.0.0.1:1338", options: []func(*Client){WithEndpoint("http://[::1]:8080")}, want: "http://[::1]:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EndpointEnv, tt.endpoint)
+			t.Setenv(EndpointModeEnv, tt.mode)
+
+			if got := NewClient(tt.options...).Endpoint(); got != tt.want {
+				t.Errorf("Expected endpoint %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewClient_InvalidEndpointMode(t *testing.T) {
+	t.Setenv(EndpointEnv, "")
+	t.Setenv(EndpointModeEnv, "dualstack")
+
+	_, err := NewClient(WithRetryPolicy(0, 0, 0)).GetMetadata(context.Background(), "instance-id")
+	if !errors.Is(err, ErrEndpointMode) {
+		t.Fatalf("Expected ErrEndpointMode, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), `"dualstack": must be IPv4 or IPv6`) {
+		t.Errorf("Expected the accepted modes in the error, got %v", err)
+	}
+}
+
+func TestClient_TokenTTL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"); got != "60" {
+			t.Errorf("Expected TTL header to be 60, got %s", got)
+		}
+		w.Write([]byte("test-token"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithTokenTTL(time.Minute))
+	if _, err := client.getToken(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if client.tokenExp.After(time.Now().Add(time.Minute)) {
+		t.Errorf("Token expiration should follow the TTL, got %v", client.tokenExp)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			w.Write([]byte("test-token"))
+		case "/latest/meta-data/unauthorized":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/latest/meta-data/broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithRetryPolicy(1, time.Millisecond, time.Millisecond))
+
+	tests := []struct {
+		path string
+		want error
+	}{
+		{"public-ipv4", ErrNotFound},
+		{"unauthorized", ErrUnauthorized},
+		{"broken", ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		_, err := client.GetMetadata(context.Background(), tt.path)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.path, tt.want, err)
+		}
+
+		var se *StatusError
+		if !errors.As(err, &se) || se.URL != "/latest/meta-data/"+tt.path {
+			t.Errorf("%s: expected a StatusError, got %v", tt.path, err)
+		}
+	}
+
+	server.Close()
+	if _, err := client.GetInstanceID(context.Background()); !errors.Is(err, ErrUnavailable) || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrUnavailable once IMDS is gone, got %v", err)
+	}
+}
+
+func TestClient_V1Fallback(t *testing.T) {
+	var tokenRequests int
+	requireToken := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			tokenRequests++
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if r.Header.Get("X-aws-ec2-metadata-token") != "" {
+			t.Error("Expected no token header in IMDSv1 mode")
+		}
+		if requireToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("i-1234567890abcdef0"))
+	}))
+	defer server.Close()
+
+	if _, err := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client())).GetInstanceID(context.Background()); err == nil {
+		t.Fatal("Expected the token error without fallback")
+	}
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithV1Fallback(true))
+	tokenRequests = 0
+
+	for range 2 {
+		id, err := client.GetInstanceID(context.Background())
+		if err != nil || id != "i-1234567890abcdef0" {
+			t.Fatalf("Expected the instance ID, got %q, %v", id, err)
+		}
+	}
+
+	if tokenRequests != 1 {
+		t.Errorf("Expected one token request before switching to IMDSv1, got %d", tokenRequests)
+	}
+
+	requireToken = true
+	if _, err := client.GetInstanceID(context.Background()); !errors.Is(err, ErrTokenRequired) {
+		t.Errorf("Expected ErrTokenRequired, got %v", err)
+	}
+}
+
+func TestClient_V1FallbackTransient(t *testing.T) {
+	var tokenRequests int
+	tokenStatus := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			tokenRequests++
+			switch {
+			case tokenStatus != 0:
+				w.WriteHeader(tokenStatus)
+			case tokenRequests == 1:
+				// Drop the connection, as a hop limit of one would.
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+			default:
+				w.Write([]byte("test-token"))
+			}
+			return
+		}
+
+		w.Write([]byte("i-1234567890abcdef0:" + r.Header.Get("X-aws-ec2-metadata-token")))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithV1Fallback(true), WithRetryPolicy(0, time.Millisecond, time.Millisecond))
+
+	for _, want := range []string{"i-1234567890abcdef0:", "i-1234567890abcdef0:test-token"} {
+		if id, err := client.GetInstanceID(context.Background()); err != nil || id != want {
+			t.Fatalf("Expected %q, got %q, %v", want, id, err)
+		}
+	}
+
+	if tokenRequests != 2 {
+		t.Errorf("Expected IMDSv2 to be tried again after a dropped token response, got %d token requests", tokenRequests)
+	}
+
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError} {
+		client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithV1Fallback(true), WithRetryPolicy(0, time.Millisecond, time.Millisecond))
+		tokenStatus = status
+
+		if _, err := client.GetInstanceID(context.Background()); err == nil {
+			t.Errorf("Expected no fallback on a %d token answer", status)
+		}
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			w.Write([]byte("test-token"))
+			return
+		}
+
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("i-1234567890abcdef0"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithRetryPolicy(2, time.Millisecond, time.Millisecond))
+
+	if id, err := client.GetInstanceID(context.Background()); err != nil || id != "i-1234567890abcdef0" {
+		t.Fatalf("Expected the instance ID after retries, got %q, %v", id, err)
+	}
+
+	requests = 0
+	client = NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithRetryPolicy(1, time.Millisecond, time.Millisecond))
+
+	if _, err := client.GetInstanceID(context.Background()); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable once retries are exhausted, got %v", err)
+	}
+}
+
+func TestClient_TokenRefresh(t *testing.T) {
+	var tokens int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			tokens++
+			fmt.Fprintf(w, "token-%d", tokens)
+			return
+		}
+
+		// Only the latest token is valid, as after an IMDS restart.
+		if r.Header.Get("X-aws-ec2-metadata-token") != fmt.Sprintf("token-%d", tokens) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("i-1234567890abcdef0"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
+	client.token = "revoked"
+	client.tokenExp = time.Now().Add(time.Hour)
+
+	if id, err := client.GetInstanceID(context.Background()); err != nil || id != "i-1234567890abcdef0" {
+		t.Fatalf("Expected the instance ID with a new token, got %q, %v", id, err)
+	}
+
+	if tokens != 1 || client.token != "token-1" {
+		t.Errorf("Expected one new token, got %d requests and %q", tokens, client.token)
+	}
+}
+
+func TestClient_TokenCallerCanceled(t *testing.T) {
+	var tokens atomic.Int32
+	requested := make(chan struct{})
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			if tokens.Add(1) == 1 {
+				close(requested)
+			}
+			<-release
+			w.Write([]byte("token"))
+			return
+		}
+		w.Write([]byte("i-1234567890abcdef0"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
+
+	// The first caller starts the token request and gives up on it.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := client.GetInstanceID(ctx)
+		first <- err
+	}()
+
+	<-requested
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the first caller to be canceled, got %v", err)
+	}
+
+	// A second caller joins the same request, which is not canceled.
+	second := make(chan error)
+	go func() {
+		_, err := client.GetInstanceID(context.Background())
+		second <- err
+	}()
+
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("Expected the second caller to get the token, got %v", err)
+	}
+
+	if n := tokens.Load(); n != 1 {
+		t.Errorf("Expected one token request, got %d", n)
+	}
+}
+
+func TestClient_Wait(t *testing.T) {
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 10 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("test"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithRetryPolicy(0, time.Millisecond, time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Wait(ctx); err != nil {
+		t.Fatalf("Expected IMDS to become ready, got %v", err)
+	}
+
+	server.Close()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := client.Wait(ctx); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable after the timeout, got %v", err)
+	}
+}
+
+func TestClient_GetMetadataBatch(t *testing.T) {
+	var tokens atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			tokens.Add(1)
+			time.Sleep(10 * time.Millisecond)
+			w.Write([]byte("test-token"))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/latest/meta-data/instance-id":
+			w.Write([]byte("i-1234567890abcdef0"))
+		case "/latest/meta-data/instance-type":
+			w.Write([]byte("t4g.small"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
+
+	results := client.GetMetadataBatch(context.Background(), "instance-id", "instance-type", "public-ipv4", "instance-id")
+
+	want := []string{"i-1234567890abcdef0", "t4g.small", "", "i-1234567890abcdef0"}
+	for i, r := range results {
+		if r.Value != want[i] {
+			t.Errorf("%s: expected %q, got %q", r.Path, want[i], r.Value)
+		}
+	}
+
+	if !errors.Is(results[2].Err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for public-ipv4, got %v", results[2].Err)
+	}
+
+	if n := tokens.Load(); n != 1 {
+		t.Errorf("Expected concurrent requests to share one token request, got %d", n)
+	}
+}
@@ -0,0 +1,138 @@
+package imds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshWindow is how long before expiry a CredentialsProvider
+// fetches new credentials. IMDS rotates role credentials at least five
+// minutes before the old ones expire.
+const DefaultRefreshWindow = 5 * time.Minute
+
+// Credentials are the temporary credentials of the instance's IAM role,
+// served at iam/security-credentials/<role>.
+type Credentials struct {
+	Code            string    `json:"Code"`
+	LastUpdated     time.Time `json:"LastUpdated"`
+	Type            string    `json:"Type"`
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+func ParseCredentials(data []byte) (*Credentials, error) {
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("parsing credentials: %w", err)
+	}
+
+	if creds.Code != "Success" {
+		return nil, fmt.Errorf("credentials not available: %s", creds.Code)
+	}
+
+	return &creds, nil
+}
+
+// GetIAMRoles lists the roles with credentials; an instance profile has
+// at most one.
+func (c *Client) GetIAMRoles(ctx context.Context) ([]string, error) {
+	roles, err := c.ListMetadataPaths(ctx, "iam/security-credentials/")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("no iam role attached: %w", ErrNotFound)
+	}
+
+	return roles, nil
+}
+
+// GetIAMCredentials returns the credentials of role, or of the instance's
+// role when role is empty.
+func (c *Client) GetIAMCredentials(ctx context.Context, role string) (*Credentials, error) {
+	if role == "" {
+		roles, err := c.GetIAMRoles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		role = roles[0]
+	}
+
+	response, err := c.GetMetadata(ctx, "iam/security-credentials/"+role)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := ParseCredentials([]byte(response))
+	if err != nil {
+		return nil, fmt.Errorf("role %s: %w", role, err)
+	}
+
+	return creds, nil
+}
+
+// CredentialsProvider caches role credentials and fetches new ones
+// shortly before they expire. It is safe for concurrent use.
+type CredentialsProvider struct {
+	client *Client
+	role   string
+	window time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	creds *Credentials
+}
+
+// WithRole fetches the credentials of role instead of looking it up.
+func WithRole(role string) func(*CredentialsProvider) {
+	return func(p *CredentialsProvider) { p.role = role }
+}
+
+// WithRefreshWindow sets how long before expiry credentials are refreshed.
+func WithRefreshWindow(window time.Duration) func(*CredentialsProvider) {
+	return func(p *CredentialsProvider) { p.window = window }
+}
+
+func NewCredentialsProvider(client *Client, options ...func(*CredentialsProvider)) *CredentialsProvider {
+	p := &CredentialsProvider{
+		client: client,
+		window: DefaultRefreshWindow,
+		now:    time.Now,
+	}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	return p
+}
+
+// Retrieve returns cached credentials, refreshing them when they are
+// within the refresh window of expiry. If the refresh fails, credentials
+// that have not yet expired are returned.
+func (p *CredentialsProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if p.creds != nil && now.Before(p.creds.Expiration.Add(-p.window)) {
+		return *p.creds, nil
+	}
+
+	creds, err := p.client.GetIAMCredentials(ctx, p.role)
+	if err != nil {
+		if p.creds != nil && now.Before(p.creds.Expiration) {
+			return *p.creds, nil
+		}
+		return Credentials{}, fmt.Errorf("refreshing credentials: %w", err)
+	}
+
+	p.creds = creds
+	return *creds, nil
+}
@@ -0,0 +1,100 @@
+package imds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_GetIAMCredentials(t *testing.T) {
+	_, client := newTestEmulator(t)
+	ctx := context.Background()
+
+	creds, err := client.GetIAMCredentials(ctx, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if creds.AccessKeyID != "ASIAEXAMPLE" || creds.SecretAccessKey != "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY" || creds.Token != "IQoJb3JpZ2luX2VjEXAMPLETOKEN" {
+		t.Errorf("Unexpected credentials %+v", creds)
+	}
+
+	if want := time.Date(2025, 1, 2, 9, 4, 5, 0, time.UTC); !creds.Expiration.Equal(want) {
+		t.Errorf("Expected expiration %v, got %v", want, creds.Expiration)
+	}
+
+	if _, err = client.GetIAMCredentials(ctx, "other-role"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown role, got %v", err)
+	}
+
+	if _, err = ParseCredentials([]byte(`{"Code": "AssumeRoleUnauthorizedAccess"}`)); err == nil {
+		t.Error("Expected an error for a failed code")
+	}
+}
+
+func TestCredentialsProvider_Retrieve(t *testing.T) {
+	var (
+		fetches atomic.Int32
+		failing atomic.Bool
+	)
+
+	expiration := time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			w.Write([]byte("test-token"))
+		case "/latest/meta-data/iam/security-credentials/mx1-role":
+			if failing.Load() {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			n := fetches.Add(1)
+			fmt.Fprintf(w, `{"Code": "Success", "AccessKeyId": "ASIA%d", "Expiration": %q}`,
+				n, expiration.Add(time.Duration(n-1)*time.Hour).Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
+	provider := NewCredentialsProvider(client, WithRole("mx1-role"))
+
+	now := expiration.Add(-time.Hour)
+	provider.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	retrieve := func(want string) {
+		t.Helper()
+		if creds, err := provider.Retrieve(ctx); err != nil || creds.AccessKeyID != want {
+			t.Errorf("Expected %s, got %q, %v", want, creds.AccessKeyID, err)
+		}
+	}
+
+	retrieve("ASIA1")
+	retrieve("ASIA1")
+
+	// Within the refresh window the next credentials are fetched.
+	now = expiration.Add(-time.Minute)
+	retrieve("ASIA2")
+
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("Expected 2 fetches, got %d", n)
+	}
+
+	// Unexpired credentials survive a failed refresh, expired ones do not.
+	failing.Store(true)
+	now = expiration.Add(time.Hour - time.Minute)
+	retrieve("ASIA2")
+
+	now = expiration.Add(2 * time.Hour)
+	if _, err := provider.Retrieve(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound once expired, got %v", err)
+	}
+}
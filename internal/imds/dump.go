@@ -0,0 +1,139 @@
+package imds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxDumpDepth stops Dump from following a listing that refers to itself.
+const maxDumpDepth = 16
+
+// Redacted replaces the values of credential leaves in a dump.
+const Redacted = "<redacted>"
+
+// credentialPaths are the subtrees holding credentials, which Dump does not
+// read unless asked to.
+var credentialPaths = []string{"iam/security-credentials/", "identity-credentials/"}
+
+type DumpOptions struct {
+	IncludeCredentials bool
+}
+
+// WithCredentials makes Dump read the IAM and identity credentials instead
+// of redacting them.
+func WithCredentials(include bool) func(*DumpOptions) {
+	return func(o *DumpOptions) { o.IncludeCredentials = include }
+}
+
+// Dump walks the metadata tree below prefix and returns it as nested maps.
+// Entries ending in "/" are descended into, as are public-keys entries of
+// the form "0=name", which are kept under their listed name. Leaves that
+// hold a JSON object or array are decoded; other leaves are strings.
+// Listed entries that turn out not to exist are left out. Leaves below
+// iam/security-credentials/ and identity-credentials/ are not read and
+// are set to Redacted, unless WithCredentials is given.
+func (c *Client) Dump(ctx context.Context, prefix string, options ...func(*DumpOptions)) (map[string]any, error) {
+	var opts DumpOptions
+	for _, option := range options {
+		option(&opts)
+	}
+
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return c.dump(ctx, prefix, 0, opts)
+}
+
+func (c *Client) dump(ctx context.Context, dir string, depth int, opts DumpOptions) (map[string]any, error) {
+	if depth > maxDumpDepth {
+		return nil, fmt.Errorf("metadata tree deeper than %d levels at %s", maxDumpDepth, dir)
+	}
+
+	entries, err := c.ListMetadataPaths(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", dir, err)
+	}
+
+	tree := map[string]any{}
+
+	var leaves []string
+	for _, entry := range entries {
+		name, sub := subdirectory(entry)
+		if !sub {
+			if !opts.IncludeCredentials && isCredentialPath(dir+entry) {
+				tree[entry] = Redacted
+			} else {
+				leaves = append(leaves, entry)
+			}
+			continue
+		}
+
+		child, err := c.dump(ctx, dir+name+"/", depth+1, opts)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		tree[strings.TrimSuffix(entry, "/")] = child
+	}
+
+	paths := make([]string, len(leaves))
+	for i, leaf := range leaves {
+		paths[i] = dir + leaf
+	}
+
+	for i, r := range c.GetMetadataBatch(ctx, paths...) {
+		if errors.Is(r.Err, ErrNotFound) {
+			continue
+		} else if r.Err != nil {
+			return nil, fmt.Errorf("getting %s: %w", r.Path, r.Err)
+		}
+
+		tree[leaves[i]] = leafValue(r.Value)
+	}
+
+	return tree, nil
+}
+
+// subdirectory reports whether a listing entry is a directory, and the
+// name to request it by.
+func subdirectory(entry string) (string, bool) {
+	if name, ok := strings.CutSuffix(entry, "/"); ok {
+		return name, true
+	}
+
+	// public-keys lists "0=name" and serves the key under 0/.
+	if index, _, ok := strings.Cut(entry, "="); ok {
+		return index, true
+	}
+
+	return "", false
+}
+
+func isCredentialPath(path string) bool {
+	for _, prefix := range credentialPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func leafValue(value string) any {
+	trimmed := bytes.TrimSpace([]byte(value))
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		var v any
+		if err := json.Unmarshal(trimmed, &v); err == nil {
+			return v
+		}
+	}
+
+	return value
+}
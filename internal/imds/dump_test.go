@@ -0,0 +1,52 @@
+package imds
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClient_Dump(t *testing.T) {
+	_, client := newTestEmulator(t)
+
+	tree, err := client.Dump(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if tree["instance-id"] != "i-1234567890abcdef0" {
+		t.Errorf("Expected instance-id leaf, got %v", tree["instance-id"])
+	}
+
+	keys := tree["public-keys"].(map[string]any)["0=mx1-key"].(map[string]any)
+	if keys["openssh-key"] != "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMx1 mx1" {
+		t.Errorf("Expected the public key below 0=mx1-key, got %v", keys)
+	}
+
+	// Credentials are redacted unless asked for.
+	if creds := tree["iam"].(map[string]any)["security-credentials"].(map[string]any)["mx1-role"]; creds != Redacted {
+		t.Errorf("Expected redacted IAM credentials, got %#v", creds)
+	}
+	identity := tree["identity-credentials"].(map[string]any)["ec2"].(map[string]any)["security-credentials"].(map[string]any)
+	if creds := identity["ec2-instance"]; creds != Redacted {
+		t.Errorf("Expected redacted identity credentials, got %#v", creds)
+	}
+
+	iam, err := client.Dump(context.Background(), "iam", WithCredentials(true))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	creds := iam["security-credentials"].(map[string]any)["mx1-role"]
+	if m, ok := creds.(map[string]any); !ok || m["Code"] != "Success" {
+		t.Errorf("Expected decoded JSON credentials, got %#v", creds)
+	}
+
+	mac := tree["network"].(map[string]any)["interfaces"].(map[string]any)["macs"].(map[string]any)["0e:00:00:00:00:01"].(map[string]any)
+	if mac["ipv6s"] != "2600:1f14::5\n2600:1f14::6" || mac["device-number"] != "0" {
+		t.Errorf("Unexpected mac entry %v", mac)
+	}
+
+	placement, err := client.Dump(context.Background(), "/placement/")
+	if err != nil || len(placement) != 2 || placement["region"] != "us-west-2" {
+		t.Errorf("Unexpected placement dump %v, %v", placement, err)
+	}
+}
@@ -0,0 +1,283 @@
+package imds
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Fixture is the metadata an Emulator serves. Maps are directories and
+// other values are leaves; lists are served one item per line. Keys such
+// as "0=my-key" are listed as is and looked up by the part before "=",
+// like public-keys.
+//
+//	meta-data:
+//	  instance-id: i-1234567890abcdef0
+//	  placement:
+//	    availability-zone: us-west-2a
+//	  public-keys:
+//	    0=my-key:
+//	      openssh-key: ssh-ed25519 AAAA...
+//	dynamic:
+//	  instance-identity:
+//	    document: '{"region": "us-west-2"}'
+//	user-data: |
+//	  #cloud-config
+//	errors:
+//	  - path: /latest/meta-data/public-ipv4
+//	    status: 404
+type Fixture struct {
+	MetaData any            `yaml:"meta-data" json:"meta-data"`
+	Dynamic  any            `yaml:"dynamic" json:"dynamic"`
+	UserData *string        `yaml:"user-data" json:"user-data"`
+	Errors   []FixtureError `yaml:"errors" json:"errors"`
+}
+
+// FixtureError makes requests for Path fail with Status. Count limits the
+// failures to the first Count requests, so retries can be exercised.
+type FixtureError struct {
+	Path   string `yaml:"path" json:"path"`
+	Method string `yaml:"method" json:"method"`
+	Status int    `yaml:"status" json:"status"`
+	Count  int    `yaml:"count" json:"count"`
+}
+
+// LoadFixture reads a YAML or JSON fixture file.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixture: %w", err)
+	}
+
+	var f Fixture
+	if err = yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing fixture %s: %w", path, err)
+	}
+
+	return &f, nil
+}
+
+const maxTokenTTL = 21600
+
+// Emulator is an http.Handler with IMDSv2 semantics: tokens are issued by
+// PUT /latest/api/token and required on every other request.
+type Emulator struct {
+	fixture *Fixture
+	allowV1 bool
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+	hits   map[int]int
+}
+
+// WithAllowV1 accepts requests without a token, like an instance with
+// HttpTokens set to optional.
+func WithAllowV1(allow bool) func(*Emulator) {
+	return func(e *Emulator) { e.allowV1 = allow }
+}
+
+func NewEmulator(fixture *Fixture, options ...func(*Emulator)) *Emulator {
+	e := &Emulator{
+		fixture: fixture,
+		tokens:  map[string]time.Time{},
+		hits:    map[int]int{},
+	}
+
+	for _, option := range options {
+		option(e)
+	}
+
+	return e
+}
+
+func (e *Emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if status, ok := e.injectedError(r); ok {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	if r.URL.Path == "/latest/api/token" {
+		e.serveToken(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !e.authorized(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	body, ok := e.lookup(strings.TrimPrefix(r.URL.Path, "/"))
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(body))
+}
+
+func (e *Emulator) injectedError(r *http.Request) (int, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, fe := range e.fixture.Errors {
+		if strings.TrimSuffix(fe.Path, "/") != strings.TrimSuffix(r.URL.Path, "/") {
+			continue
+		}
+		if fe.Method != "" && !strings.EqualFold(fe.Method, r.Method) {
+			continue
+		}
+		if fe.Count > 0 && e.hits[i] >= fe.Count {
+			continue
+		}
+
+		e.hits[i]++
+		return fe.Status, true
+	}
+
+	return 0, false
+}
+
+func (e *Emulator) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// IMDS refuses tokens to requests that went through a proxy.
+	if r.Header.Get("X-Forwarded-For") != "" {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	ttl, err := strconv.Atoi(r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+	if err != nil || ttl < 1 || ttl > maxTokenTTL {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	e.mu.Lock()
+	e.tokens[token] = time.Now().Add(time.Duration(ttl) * time.Second)
+	e.mu.Unlock()
+
+	w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(ttl))
+	_, _ = w.Write([]byte(token))
+}
+
+func (e *Emulator) authorized(r *http.Request) bool {
+	token := r.Header.Get("X-aws-ec2-metadata-token")
+	if token == "" {
+		return e.allowV1
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	exp, ok := e.tokens[token]
+	return ok && time.Now().Before(exp)
+}
+
+// lookup renders the document or directory listing at path.
+func (e *Emulator) lookup(path string) (string, bool) {
+	root := map[string]any{
+		"latest": map[string]any{
+			"meta-data": e.fixture.MetaData,
+			"dynamic":   e.fixture.Dynamic,
+		},
+	}
+
+	if e.fixture.UserData != nil {
+		root["latest"].(map[string]any)["user-data"] = *e.fixture.UserData
+	}
+
+	var node any = root
+	for _, seg := range strings.Split(strings.TrimSuffix(path, "/"), "/") {
+		if seg == "" {
+			continue
+		}
+
+		dir, ok := asMap(node)
+		if !ok {
+			return "", false
+		}
+
+		if node, ok = child(dir, seg); !ok || node == nil {
+			return "", false
+		}
+	}
+
+	return render(node), true
+}
+
+func child(dir map[string]any, name string) (any, bool) {
+	if v, ok := dir[name]; ok {
+		return v, true
+	}
+
+	for k, v := range dir {
+		if before, _, ok := strings.Cut(k, "="); ok && before == name {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// asMap accepts both map kinds the YAML decoder produces.
+func asMap(node any) (map[string]any, bool) {
+	switch m := node.(type) {
+	case map[string]any:
+		return m, true
+	case map[any]any:
+		res := make(map[string]any, len(m))
+		for k, v := range m {
+			res[fmt.Sprint(k)] = v
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+func render(node any) string {
+	if dir, ok := asMap(node); ok {
+		var names []string
+		for k, v := range dir {
+			if _, sub := asMap(v); sub && !strings.Contains(k, "=") {
+				k += "/"
+			}
+			if v != nil {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+		return strings.Join(names, "\n")
+	}
+
+	if list, ok := node.([]any); ok {
+		lines := make([]string, len(list))
+		for i, v := range list {
+			lines[i] = fmt.Sprint(v)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	return fmt.Sprint(node)
+}
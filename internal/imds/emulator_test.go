@@ -0,0 +1,125 @@
+package imds
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestEmulator(t *testing.T, options ...func(*Emulator)) (*httptest.Server, *Client) {
+	t.Helper()
+
+	fixture, err := LoadFixture("testdata/fixture.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewEmulator(fixture, options...))
+	t.Cleanup(server.Close)
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithRetryPolicy(3, time.Millisecond, time.Millisecond))
+
+	return server, client
+}
+
+func TestEmulator_Metadata(t *testing.T) {
+	_, client := newTestEmulator(t)
+	ctx := context.Background()
+
+	paths, err := client.ListMetadataPaths(ctx, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := strings.Join(paths, " "); got != "ami-id hostname iam/ identity-credentials/ instance-id instance-type local-ipv4 mac network/ placement/ public-keys/" {
+		t.Errorf("Unexpected listing %q", got)
+	}
+
+	if keys, _ := client.ListMetadataPaths(ctx, "public-keys/"); len(keys) != 1 || keys[0] != "0=mx1-key" {
+		t.Errorf("Expected the public key listed as 0=mx1-key, got %v", keys)
+	}
+
+	for path, want := range map[string]string{
+		"public-keys/0/openssh-key": "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIMx1 mx1",
+		"placement/region":          "us-west-2",
+		"instance-type":             "t4g.small",
+	} {
+		if got, err := client.GetMetadata(ctx, path); err != nil || got != want {
+			t.Errorf("%s: expected %q, got %q, %v", path, want, got, err)
+		}
+	}
+
+	if ipv6, err := client.GetIPv6(ctx); err != nil || ipv6 != "2600:1f14::5" {
+		t.Errorf("Expected the first ipv6, got %q, %v", ipv6, err)
+	}
+
+	if _, err := client.GetPublicIPv4(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if doc, err := client.GetInstanceIdentityDocument(ctx); err != nil || !strings.Contains(doc, `"accountId": "123456789012"`) {
+		t.Errorf("Unexpected identity document %q, %v", doc, err)
+	}
+
+	if ud, err := client.GetUserData(ctx); err != nil || ud != "#cloud-config\nhostname: mx1\n" {
+		t.Errorf("Unexpected user data %q, %v", ud, err)
+	}
+}
+
+func TestEmulator_Tokens(t *testing.T) {
+	server, _ := newTestEmulator(t)
+
+	request := func(method, path string, header map[string]string) int {
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header map[string]string
+		want   int
+	}{
+		{"no token", "GET", "/latest/meta-data/instance-id", nil, http.StatusUnauthorized},
+		{"invalid token", "GET", "/latest/meta-data/instance-id", map[string]string{"X-aws-ec2-metadata-token": "forged"}, http.StatusUnauthorized},
+		{"token without ttl", "PUT", "/latest/api/token", nil, http.StatusBadRequest},
+		{"token ttl too long", "PUT", "/latest/api/token", map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "21601"}, http.StatusBadRequest},
+		{"token through proxy", "PUT", "/latest/api/token", map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60", "X-Forwarded-For": "10.0.0.1"}, http.StatusForbidden},
+		{"token with get", "GET", "/latest/api/token", nil, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		if got := request(tt.method, tt.path, tt.header); got != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, got)
+		}
+	}
+
+	server, _ = newTestEmulator(t, WithAllowV1(true))
+	if got := request("GET", "/latest/meta-data/instance-id", nil); got != http.StatusOK {
+		t.Errorf("Expected IMDSv1 requests to be allowed, got status %d", got)
+	}
+}
+
+func TestEmulator_InjectedErrors(t *testing.T) {
+	_, client := newTestEmulator(t)
+
+	// The first two requests fail with 503 and are retried.
+	if got, err := client.GetInstanceType(context.Background()); err != nil || got != "t4g.small" {
+		t.Errorf("Expected the instance type after retries, got %q, %v", got, err)
+	}
+}
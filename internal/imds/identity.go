@@ -0,0 +1,216 @@
+package imds
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+)
+
+// Signature kinds served next to the document under
+// dynamic/instance-identity/. Only rsa2048 can be verified here: the pkcs7
+// signature uses a DSA certificate, which crypto/x509 no longer checks.
+const (
+	IdentitySignaturePKCS7   = "pkcs7"
+	IdentitySignatureRSA2048 = "rsa2048"
+)
+
+var (
+	ErrUnknownRegion    = errors.New("no bundled certificate for region")
+	ErrInvalidSignature = errors.New("invalid instance identity signature")
+)
+
+// IdentityDocument is the instance identity document served at
+// dynamic/instance-identity/document.
+type IdentityDocument struct {
+	AccountID               string    `json:"accountId"`
+	Architecture            string    `json:"architecture"`
+	AvailabilityZone        string    `json:"availabilityZone"`
+	BillingProducts         []string  `json:"billingProducts"`
+	DevpayProductCodes      []string  `json:"devpayProductCodes"`
+	MarketplaceProductCodes []string  `json:"marketplaceProductCodes"`
+	ImageID                 string    `json:"imageId"`
+	InstanceID              string    `json:"instanceId"`
+	InstanceType            string    `json:"instanceType"`
+	KernelID                string    `json:"kernelId"`
+	PendingTime             time.Time `json:"pendingTime"`
+	PrivateIP               string    `json:"privateIp"`
+	RamdiskID               string    `json:"ramdiskId"`
+	Region                  string    `json:"region"`
+	Version                 string    `json:"version"`
+}
+
+func ParseIdentityDocument(data []byte) (*IdentityDocument, error) {
+	var doc IdentityDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing instance identity document: %w", err)
+	}
+
+	return &doc, nil
+}
+
+// Partition is the AWS partition of the document's region.
+func (d *IdentityDocument) Partition() string {
+	return Partition(d.Region)
+}
+
+// Partition returns the AWS partition a region belongs to.
+func Partition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
+// SignedIdentity is the raw document together with its signatures, as
+// handed to a service that verifies it.
+type SignedIdentity struct {
+	Document string `json:"document"`
+	PKCS7    string `json:"pkcs7"`
+	RSA2048  string `json:"rsa2048"`
+}
+
+func (c *Client) GetIdentityDocument(ctx context.Context) (*IdentityDocument, error) {
+	document, err := c.GetInstanceIdentityDocument(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseIdentityDocument([]byte(document))
+}
+
+// GetIdentitySignature returns the signature of the given kind, base64
+// encoded as served.
+func (c *Client) GetIdentitySignature(ctx context.Context, kind string) (string, error) {
+	return c.GetDynamic(ctx, "instance-identity/"+kind)
+}
+
+func (c *Client) GetSignedIdentity(ctx context.Context) (*SignedIdentity, error) {
+	var (
+		id  SignedIdentity
+		err error
+	)
+
+	if id.Document, err = c.GetInstanceIdentityDocument(ctx); err != nil {
+		return nil, fmt.Errorf("getting document: %w", err)
+	}
+	if id.PKCS7, err = c.GetIdentitySignature(ctx, IdentitySignaturePKCS7); err != nil {
+		return nil, fmt.Errorf("getting %s signature: %w", IdentitySignaturePKCS7, err)
+	}
+	if id.RSA2048, err = c.GetIdentitySignature(ctx, IdentitySignatureRSA2048); err != nil {
+		return nil, fmt.Errorf("getting %s signature: %w", IdentitySignatureRSA2048, err)
+	}
+
+	return &id, nil
+}
+
+// Verify checks the rsa2048 signature against the bundled certificate for
+// the document's region and returns the parsed document.
+func (id *SignedIdentity) Verify() (*IdentityDocument, error) {
+	return VerifyIdentityDocument([]byte(id.Document), id.RSA2048)
+}
+
+// certs holds the AWS RSA-2048 instance identity certificates, one file
+// per region under a directory per partition. They are copied from the
+// "RSA-2048 certificate" section of the AWS public certificates page,
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/regions-certs.html,
+// which is where to check the bundle and add new regions. Not bundled yet:
+// ca-west-1, il-central-1, mx-central-1, ap-southeast-5, ap-southeast-7.
+//
+//go:embed certs
+var certs embed.FS
+
+// IdentityCertificate returns the bundled RSA-2048 certificate that signs
+// instance identity documents in region.
+func IdentityCertificate(region string) (*x509.Certificate, error) {
+	if region == "" || strings.ContainsAny(region, "/.") {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownRegion, region)
+	}
+
+	data, err := certs.ReadFile("certs/" + Partition(region) + "/" + region + ".pem")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRegion, region)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("decoding certificate for %s", region)
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// IdentityRegions lists the regions with a bundled certificate.
+func IdentityRegions() []string {
+	var regions []string
+
+	partitions, _ := certs.ReadDir("certs")
+	for _, partition := range partitions {
+		files, _ := certs.ReadDir("certs/" + partition.Name())
+		for _, file := range files {
+			regions = append(regions, strings.TrimSuffix(file.Name(), ".pem"))
+		}
+	}
+
+	return regions
+}
+
+// VerifyIdentityDocument verifies an rsa2048 signature of document with
+// the bundled certificate for the region named in the document.
+func VerifyIdentityDocument(document []byte, signature string) (*IdentityDocument, error) {
+	doc, err := ParseIdentityDocument(document)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := IdentityCertificate(doc.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = VerifyIdentitySignature(document, signature, cert); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// VerifyIdentitySignature checks that signature is a PKCS7 signature of
+// document made by cert. The signature embeds the signed document, which
+// must match the one given.
+func VerifyIdentitySignature(document []byte, signature string, cert *x509.Certificate) error {
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(signature), ""))
+	if err != nil {
+		return fmt.Errorf("%w: decoding: %v", ErrInvalidSignature, err)
+	}
+
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		return fmt.Errorf("%w: parsing: %v", ErrInvalidSignature, err)
+	}
+
+	// Only trust the bundled certificate, not any the signature carries.
+	p7.Certificates = []*x509.Certificate{cert}
+	if err = p7.Verify(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	if !bytes.Equal(bytes.TrimSpace(p7.Content), bytes.TrimSpace(document)) {
+		return fmt.Errorf("%w: signed content does not match document", ErrInvalidSignature)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package imds
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+)
+
+const testIdentityDocument = `{
+  "accountId" : "123456789012",
+  "architecture" : "arm64",
+  "availabilityZone" : "us-west-2a",
+  "billingProducts" : null,
+  "devpayProductCodes" : null,
+  "marketplaceProductCodes" : null,
+  "imageId" : "ami-0abcdef1234567890",
+  "instanceId" : "i-1234567890abcdef0",
+  "instanceType" : "t4g.small",
+  "kernelId" : null,
+  "pendingTime" : "2025-01-02T03:04:05Z",
+  "privateIp" : "10.0.0.5",
+  "ramdiskId" : null,
+  "region" : "us-west-2",
+  "version" : "2017-09-30"
+}`
+
+// newTestSigner returns a self-signed certificate standing in for the
+// regional AWS certificate, and a function signing with it.
+func newTestSigner(t *testing.T) (*x509.Certificate, func(string) string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"Amazon Web Services LLC"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, func(document string) string {
+		sd, err := pkcs7.NewSignedData([]byte(document))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = sd.AddSigner(cert, key, pkcs7.SignerInfoConfig{}); err != nil {
+			t.Fatal(err)
+		}
+		signed, err := sd.Finish()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(signed)
+	}
+}
+
+func TestParseIdentityDocument(t *testing.T) {
+	doc, err := ParseIdentityDocument([]byte(testIdentityDocument))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if doc.AccountID != "123456789012" || doc.InstanceID != "i-1234567890abcdef0" || doc.Region != "us-west-2" || doc.Architecture != "arm64" {
+		t.Errorf("Unexpected document %+v", doc)
+	}
+
+	if want := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC); !doc.PendingTime.Equal(want) {
+		t.Errorf("Expected pending time %v, got %v", want, doc.PendingTime)
+	}
+
+	if _, err = ParseIdentityDocument([]byte("not json")); err == nil {
+		t.Error("Expected an error for an invalid document")
+	}
+}
+
+func TestIdentityCertificate(t *testing.T) {
+	regions := IdentityRegions()
+	if len(regions) == 0 {
+		t.Fatal("Expected bundled certificates")
+	}
+
+	for _, region := range regions {
+		cert, err := IdentityCertificate(region)
+		if err != nil {
+			t.Errorf("%s: %v", region, err)
+			continue
+		}
+		if key, ok := cert.PublicKey.(*rsa.PublicKey); !ok || key.N.BitLen() != 2048 {
+			t.Errorf("%s: expected an RSA-2048 key", region)
+		}
+	}
+
+	for region, partition := range map[string]string{
+		"us-east-1":     "aws",
+		"cn-north-1":    "aws-cn",
+		"us-gov-west-1": "aws-us-gov",
+	} {
+		if got := Partition(region); got != partition {
+			t.Errorf("%s: expected partition %s, got %s", region, partition, got)
+		}
+		if _, err := IdentityCertificate(region); err != nil {
+			t.Errorf("%s: %v", region, err)
+		}
+	}
+
+	for _, region := range []string{"", "xx-nowhere-1", "../aws/us-east-1"} {
+		if _, err := IdentityCertificate(region); !errors.Is(err, ErrUnknownRegion) {
+			t.Errorf("%q: expected ErrUnknownRegion, got %v", region, err)
+		}
+	}
+}
+
+func TestVerifyIdentitySignature(t *testing.T) {
+	cert, sign := newTestSigner(t)
+	other, _ := newTestSigner(t)
+	signature := sign(testIdentityDocument)
+
+	if err := VerifyIdentitySignature([]byte(testIdentityDocument+"\n"), signature, cert); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+
+	tampered := []byte(`{"accountId": "999999999999", "region": "us-west-2"}`)
+	for name, err := range map[string]error{
+		"other certificate": VerifyIdentitySignature([]byte(testIdentityDocument), signature, other),
+		"tampered document": VerifyIdentitySignature(tampered, signature, cert),
+		"garbage":           VerifyIdentitySignature([]byte(testIdentityDocument), "not base64!", cert),
+	} {
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+
+	// The test signer is not the bundled us-west-2 certificate.
+	if _, err := VerifyIdentityDocument([]byte(testIdentityDocument), signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature against the bundled certificate, got %v", err)
+	}
+}
+
+func TestClient_GetSignedIdentity(t *testing.T) {
+	cert, sign := newTestSigner(t)
+
+	server := httptest.NewServer(NewEmulator(&Fixture{
+		Dynamic: map[string]any{
+			"instance-identity": map[string]any{
+				"document": testIdentityDocument,
+				"pkcs7":    "MIAGCSqGSIb3DQEHAqCAMIACAQExCzAJBgUrDgMCGgUA",
+				"rsa2048":  sign(testIdentityDocument),
+			},
+		},
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
+	ctx := context.Background()
+
+	doc, err := client.GetIdentityDocument(ctx)
+	if err != nil || doc.InstanceID != "i-1234567890abcdef0" {
+		t.Fatalf("Unexpected document %+v, %v", doc, err)
+	}
+
+	id, err := client.GetSignedIdentity(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if id.PKCS7 == "" || id.RSA2048 == "" {
+		t.Errorf("Expected both signatures, got %+v", id)
+	}
+
+	if err = VerifyIdentitySignature([]byte(id.Document), id.RSA2048, cert); err != nil {
+		t.Errorf("Expected the served signature to verify, got %v", err)
+	}
+}
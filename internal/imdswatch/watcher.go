@@ -0,0 +1,390 @@
+package imdswatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/imds"
+)
+
+// DefaultInterval is how often IMDS is polled. A Spot interruption notice
+// comes two minutes ahead, and AWS suggests checking every five seconds.
+const DefaultInterval = 5 * time.Second
+
+var ErrInvalidInterval = errors.New("invalid interval")
+
+const (
+	SpotInstanceActionPath = "spot/instance-action"
+	RebalancePath          = "events/recommendations/rebalance"
+	MaintenancePath        = "events/maintenance/scheduled"
+)
+
+// paths are the notices polled, with the kind of event each one holds.
+var paths = []struct {
+	path  string
+	kind  Kind
+	parse func(string) ([]Event, error)
+}{
+	{SpotInstanceActionPath, KindSpotInterruption, parseSpotInstanceAction},
+	{RebalancePath, KindRebalance, parseRebalance},
+	{MaintenancePath, KindMaintenance, parseMaintenance},
+}
+
+// maintenanceTimeLayout is the format of NotBefore and NotAfter in
+// scheduled maintenance events.
+const maintenanceTimeLayout = "2 Jan 2006 15:04:05 GMT"
+
+type Kind string
+
+const (
+	KindSpotInterruption Kind = "spot-interruption"
+	KindRebalance        Kind = "rebalance"
+	KindMaintenance      Kind = "maintenance"
+)
+
+// Event is one notice read from IMDS. ID stays the same for as long as
+// IMDS serves the same notice, and is what hooks are de-duplicated on.
+type Event struct {
+	Kind   Kind      `json:"kind"`
+	ID     string    `json:"id"`
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+	Raw    string    `json:"raw"`
+}
+
+// Hook is run once for each new event.
+type Hook func(context.Context, Event) error
+
+type hook struct {
+	kind Kind
+	run  Hook
+}
+
+// Watcher polls IMDS for Spot interruption, rebalance recommendation and
+// scheduled maintenance notices, and runs hooks for new ones.
+type Watcher struct {
+	client    *imds.Client
+	interval  time.Duration
+	hooks     []hook
+	stateFile string
+	logger    *log.Logger
+
+	seen   map[string]time.Time
+	loaded bool
+}
+
+func WithClient(client *imds.Client) func(*Watcher) { return func(w *Watcher) { w.client = client } }
+
+func WithInterval(interval time.Duration) func(*Watcher) {
+	return func(w *Watcher) { w.interval = interval }
+}
+
+// WithHook adds a hook for events of kind, or for all events when kind is
+// empty. Hooks run one after the other in the order they were added.
+func WithHook(kind Kind, run Hook) func(*Watcher) {
+	return func(w *Watcher) { w.hooks = append(w.hooks, hook{kind: kind, run: run}) }
+}
+
+// WithStateFile remembers handled events in path, so a restarted watcher
+// does not run hooks for them again.
+func WithStateFile(path string) func(*Watcher) { return func(w *Watcher) { w.stateFile = path } }
+
+func WithLogger(logger *log.Logger) func(*Watcher) { return func(w *Watcher) { w.logger = logger } }
+
+func NewWatcher(options ...func(*Watcher)) *Watcher {
+	w := &Watcher{
+		interval: DefaultInterval,
+		logger:   log.Default(),
+		seen:     map[string]time.Time{},
+	}
+
+	for _, fn := range options {
+		fn(w)
+	}
+
+	if w.client == nil {
+		w.client = imds.NewClient()
+	}
+
+	return w
+}
+
+// Check polls IMDS once and runs the hooks for events not handled before.
+// Failed hooks are logged and not retried. A notice that cannot be read
+// does not keep the others from being handled; the failures are returned
+// together, naming each path. It returns the new events.
+func (w *Watcher) Check(ctx context.Context) ([]Event, error) {
+	if err := w.loadState(); err != nil {
+		return nil, err
+	}
+
+	events, read, pollErr := w.poll(ctx)
+
+	var fresh []Event
+	for _, e := range events {
+		if _, ok := w.seen[e.ID]; ok {
+			continue
+		}
+
+		w.logger.Printf("%s %s: %s %s", e.Kind, e.ID, e.Action, e.Time.Format(time.RFC3339))
+		w.runHooks(ctx, e)
+
+		w.seen[e.ID] = time.Now()
+		fresh = append(fresh, e)
+	}
+
+	if w.forget(events, read) || len(fresh) > 0 {
+		if err := w.saveState(); err != nil {
+			w.logger.Printf("saving state: %v", err)
+		}
+	}
+
+	return fresh, pollErr
+}
+
+// Run checks every interval until ctx is done. Failed checks are logged
+// and retried on the next tick.
+func (w *Watcher) Run(ctx context.Context) error {
+	if w.interval <= 0 {
+		return fmt.Errorf("%w %s: must be positive", ErrInvalidInterval, w.interval)
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Check(ctx); err != nil {
+			w.logger.Printf("imds watch check failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads each notice path on its own. It returns the events of the
+// paths that could be read, the kinds read (including those with no
+// notice), and the failures of the others.
+func (w *Watcher) poll(ctx context.Context) ([]Event, map[Kind]bool, error) {
+	var (
+		events []Event
+		errs   []error
+		read   = map[Kind]bool{}
+	)
+
+	batch := make([]string, len(paths))
+	for i, p := range paths {
+		batch[i] = p.path
+	}
+
+	for i, r := range w.client.GetMetadataBatch(ctx, batch...) {
+		var (
+			parsed []Event
+			err    error
+		)
+
+		switch {
+		case errors.Is(r.Err, imds.ErrNotFound):
+		case r.Err != nil:
+			err = fmt.Errorf("getting %s: %w", r.Path, r.Err)
+		default:
+			if parsed, err = paths[i].parse(r.Value); err != nil {
+				err = fmt.Errorf("parsing %s: %w", r.Path, err)
+			}
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		read[paths[i].kind] = true
+		events = append(events, parsed...)
+	}
+
+	return events, read, errors.Join(errs...)
+}
+
+// forget drops the handled events of the kinds read that IMDS no longer
+// serves, so the state does not grow forever. Kinds that could not be read
+// are kept. It reports whether anything was dropped.
+func (w *Watcher) forget(events []Event, read map[Kind]bool) bool {
+	served := map[string]bool{}
+	for _, e := range events {
+		served[e.ID] = true
+	}
+
+	var dropped bool
+	for id := range w.seen {
+		kind, _, _ := strings.Cut(id, "/")
+		if read[Kind(kind)] && !served[id] {
+			delete(w.seen, id)
+			dropped = true
+		}
+	}
+
+	return dropped
+}
+
+func (w *Watcher) runHooks(ctx context.Context, e Event) {
+	for _, h := range w.hooks {
+		if h.kind != "" && h.kind != e.Kind {
+			continue
+		}
+
+		if err := h.run(ctx, e); err != nil {
+			w.logger.Printf("%s hook failed: %v", e.Kind, err)
+		}
+	}
+}
+
+func parseSpotInstanceAction(value string) ([]Event, error) {
+	var notice struct {
+		Action string    `json:"action"`
+		Time   time.Time `json:"time"`
+	}
+	if err := json.Unmarshal([]byte(value), &notice); err != nil {
+		return nil, err
+	}
+
+	return []Event{{
+		Kind:   KindSpotInterruption,
+		ID:     fmt.Sprintf("%s/%s/%s", KindSpotInterruption, notice.Action, notice.Time.Format(time.RFC3339)),
+		Action: notice.Action,
+		Time:   notice.Time,
+		Raw:    value,
+	}}, nil
+}
+
+func parseRebalance(value string) ([]Event, error) {
+	var notice struct {
+		NoticeTime time.Time `json:"noticeTime"`
+	}
+	if err := json.Unmarshal([]byte(value), &notice); err != nil {
+		return nil, err
+	}
+
+	return []Event{{
+		Kind:   KindRebalance,
+		ID:     fmt.Sprintf("%s/%s", KindRebalance, notice.NoticeTime.Format(time.RFC3339)),
+		Action: "rebalance",
+		Time:   notice.NoticeTime,
+		Raw:    value,
+	}}, nil
+}
+
+// parseMaintenance returns an event for each scheduled maintenance that
+// has not been completed or canceled.
+func parseMaintenance(value string) ([]Event, error) {
+	var scheduled []struct {
+		Code        string
+		Description string
+		EventID     string `json:"EventId"`
+		NotBefore   string
+		NotAfter    string
+		State       string
+	}
+	if err := json.Unmarshal([]byte(value), &scheduled); err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, s := range scheduled {
+		if s.State == "completed" || s.State == "canceled" {
+			continue
+		}
+
+		raw, _ := json.Marshal(s)
+		notBefore, _ := time.Parse(maintenanceTimeLayout, s.NotBefore)
+
+		events = append(events, Event{
+			Kind:   KindMaintenance,
+			ID:     fmt.Sprintf("%s/%s", KindMaintenance, s.EventID),
+			Action: s.Code,
+			Time:   notBefore,
+			Raw:    string(raw),
+		})
+	}
+
+	return events, nil
+}
+
+// CommandHook runs command with /bin/sh. The event is passed in the
+// environment as IMDS_EVENT_KIND, IMDS_EVENT_ID, IMDS_EVENT_ACTION,
+// IMDS_EVENT_TIME and IMDS_EVENT (the notice as served). The command is
+// killed after timeout, if set.
+func CommandHook(command string, timeout time.Duration) Hook {
+	return func(ctx context.Context, e Event) error {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(),
+			"IMDS_EVENT_KIND="+string(e.Kind),
+			"IMDS_EVENT_ID="+e.ID,
+			"IMDS_EVENT_ACTION="+e.Action,
+			"IMDS_EVENT_TIME="+e.Time.Format(time.RFC3339),
+			"IMDS_EVENT="+e.Raw,
+		)
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("running %q: %w", command, err)
+		}
+		return nil
+	}
+}
+
+// loadState reads the state file the first time it is needed.
+func (w *Watcher) loadState() error {
+	if w.stateFile == "" || w.loaded {
+		return nil
+	}
+
+	data, err := os.ReadFile(w.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		w.loaded = true
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading state: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &w.seen); err != nil {
+		return fmt.Errorf("parsing state %s: %w", w.stateFile, err)
+	}
+
+	w.loaded = true
+	return nil
+}
+
+func (w *Watcher) saveState() error {
+	if w.stateFile == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(w.stateFile), 0755); err != nil {
+		return fmt.Errorf("creating directory for %q: %w", w.stateFile, err)
+	}
+
+	data, err := json.MarshalIndent(w.seen, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(w.stateFile, data, 0644)
+}
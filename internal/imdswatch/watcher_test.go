@@ -0,0 +1,194 @@
+package imdswatch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/imds"
+)
+
+const maintenanceJSON = `[
+  {"NotBefore": "21 Jan 2026 09:00:43 GMT", "Code": "system-reboot", "Description": "scheduled reboot", "EventId": "instance-event-0d59937288b749b32", "NotAfter": "21 Jan 2026 09:17:23 GMT", "State": "active"},
+  {"NotBefore": "01 Jan 2026 09:00:43 GMT", "Code": "instance-stop", "Description": "done", "EventId": "instance-event-0123456789abcdef0", "NotAfter": "01 Jan 2026 09:17:23 GMT", "State": "completed"}
+]`
+
+func newTestWatcher(t *testing.T, fixture *imds.Fixture, options ...func(*Watcher)) *Watcher {
+	t.Helper()
+
+	server := httptest.NewServer(imds.NewEmulator(fixture))
+	t.Cleanup(server.Close)
+
+	client := imds.NewClient(imds.WithEndpoint(server.URL), imds.WithHTTPClient(server.Client()), imds.WithRetryPolicy(0, 0, 0))
+
+	return NewWatcher(append([]func(*Watcher){
+		WithClient(client),
+		WithLogger(log.New(io.Discard, "", 0)),
+	}, options...)...)
+}
+
+func TestWatcher_Check(t *testing.T) {
+	var handled []string
+	record := func(prefix string) Hook {
+		return func(_ context.Context, e Event) error {
+			handled = append(handled, prefix+":"+e.ID)
+			return nil
+		}
+	}
+
+	w := newTestWatcher(t, &imds.Fixture{MetaData: map[string]any{
+		"spot": map[string]any{
+			"instance-action": `{"action": "terminate", "time": "2026-10-18T08:22:00Z"}`,
+		},
+		"events": map[string]any{
+			"recommendations": map[string]any{"rebalance": `{"noticeTime": "2026-10-18T08:17:00Z"}`},
+			"maintenance":     map[string]any{"scheduled": maintenanceJSON},
+		},
+	}},
+		WithHook(KindSpotInterruption, record("spot")),
+		WithHook("", record("all")),
+	)
+
+	ctx := context.Background()
+
+	events, err := w.Check(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %+v", events)
+	}
+
+	spot := events[0]
+	if spot.Kind != KindSpotInterruption || spot.Action != "terminate" || !spot.Time.Equal(time.Date(2026, 10, 18, 8, 22, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected spot event %+v", spot)
+	}
+
+	if maint := events[2]; maint.Action != "system-reboot" || maint.ID != "maintenance/instance-event-0d59937288b749b32" || maint.Time.Day() != 21 {
+		t.Errorf("Unexpected maintenance event %+v", maint)
+	}
+
+	want := []string{
+		"spot:spot-interruption/terminate/2026-10-18T08:22:00Z",
+		"all:spot-interruption/terminate/2026-10-18T08:22:00Z",
+		"all:rebalance/2026-10-18T08:17:00Z",
+		"all:maintenance/instance-event-0d59937288b749b32",
+	}
+	if strings.Join(handled, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected hooks %v, got %v", want, handled)
+	}
+
+	// The same notices are served again, but hooks only run once.
+	if events, err = w.Check(ctx); err != nil || len(events) != 0 {
+		t.Errorf("Expected no new events, got %+v, %v", events, err)
+	}
+	if len(handled) != len(want) {
+		t.Errorf("Expected no further hook runs, got %v", handled)
+	}
+}
+
+func TestWatcher_NoEvents(t *testing.T) {
+	w := newTestWatcher(t, &imds.Fixture{MetaData: map[string]any{"instance-id": "i-1234567890abcdef0"}})
+
+	if events, err := w.Check(context.Background()); err != nil || len(events) != 0 {
+		t.Errorf("Expected no events, got %+v, %v", events, err)
+	}
+}
+
+func TestWatcher_StateFile(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state", "imds-watch.json")
+	fixture := &imds.Fixture{MetaData: map[string]any{
+		"events": map[string]any{
+			"recommendations": map[string]any{"rebalance": `{"noticeTime": "2026-10-18T08:17:00Z"}`},
+		},
+	}}
+
+	var runs int
+	count := WithHook("", func(context.Context, Event) error { runs++; return nil })
+
+	if _, err := newTestWatcher(t, fixture, count, WithStateFile(state)).Check(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A restarted watcher remembers the handled notice.
+	if _, err := newTestWatcher(t, fixture, count, WithStateFile(state)).Check(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if runs != 1 {
+		t.Errorf("Expected one hook run across restarts, got %d", runs)
+	}
+}
+
+func TestWatcher_PathError(t *testing.T) {
+	var handled []string
+	w := newTestWatcher(t, &imds.Fixture{
+		MetaData: map[string]any{
+			"spot": map[string]any{
+				"instance-action": `{"action": "stop", "time": "2026-10-18T08:22:00Z"}`,
+			},
+			"events": map[string]any{
+				"maintenance": map[string]any{"scheduled": maintenanceJSON},
+			},
+		},
+		Errors: []imds.FixtureError{{Path: "/latest/meta-data/" + MaintenancePath, Status: 500}},
+	}, WithHook("", func(_ context.Context, e Event) error {
+		handled = append(handled, e.ID)
+		return nil
+	}))
+
+	// Handled before: a rebalance notice IMDS no longer serves, and a
+	// maintenance notice that cannot be read now.
+	w.seen["rebalance/2026-10-01T08:17:00Z"] = time.Now()
+	w.seen["maintenance/instance-event-0d59937288b749b32"] = time.Now()
+
+	events, err := w.Check(context.Background())
+	if err == nil || !strings.Contains(err.Error(), MaintenancePath) {
+		t.Errorf("Expected an error naming %s, got %v", MaintenancePath, err)
+	}
+
+	// The Spot notice is still handled.
+	if len(events) != 1 || len(handled) != 1 || handled[0] != "spot-interruption/stop/2026-10-18T08:22:00Z" {
+		t.Errorf("Expected the spot event to be handled, got %+v, %v", events, handled)
+	}
+
+	if _, ok := w.seen["rebalance/2026-10-01T08:17:00Z"]; ok {
+		t.Error("Expected the rebalance notice no longer served to be forgotten")
+	}
+	if _, ok := w.seen["maintenance/instance-event-0d59937288b749b32"]; !ok {
+		t.Error("Expected the maintenance notice that could not be read to be kept")
+	}
+}
+
+func TestWatcher_InvalidInterval(t *testing.T) {
+	w := newTestWatcher(t, &imds.Fixture{}, WithInterval(0))
+
+	if err := w.Run(context.Background()); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("Expected ErrInvalidInterval, got %v", err)
+	}
+}
+
+func TestCommandHook(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	hook := CommandHook(`printf '%s %s' "$IMDS_EVENT_KIND" "$IMDS_EVENT_ACTION" > `+out, time.Minute)
+
+	if err := hook(context.Background(), Event{Kind: KindSpotInterruption, Action: "stop"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if data, _ := os.ReadFile(out); string(data) != "spot-interruption stop" {
+		t.Errorf("Unexpected hook output %q", data)
+	}
+
+	if err := CommandHook("exit 3", time.Minute)(context.Background(), Event{}); err == nil {
+		t.Error("Expected an error from a failing command")
+	}
+}
@@ -0,0 +1,72 @@
+package maddy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var macroDefinition = regexp.MustCompile(`^\$\(([a-z_]+)\)\s*=\s*(.*)$`)
+
+// ConfigFile is the maddy.conf that Config edits.
+func (c ConfigParameters) ConfigFile() string {
+	return filepath.Join(c.Root(), "etc/maddy/maddy.conf")
+}
+
+// ReadDomains returns the mail domains of a maddy.conf: the primary domain
+// followed by the other local domains.
+func ReadDomains(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening maddy config: %w", err)
+	}
+	defer f.Close()
+
+	return ParseDomains(f)
+}
+
+// ParseDomains expands $(primary_domain) and $(local_domains) from maddy
+// config contents, without duplicates.
+func ParseDomains(r io.Reader) ([]string, error) {
+	macros := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := macroDefinition.FindStringSubmatch(line); m != nil {
+			macros[m[1]] = m[2]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading maddy config: %w", err)
+	}
+
+	expand := func(s string) string {
+		for name, value := range macros {
+			s = strings.ReplaceAll(s, "$("+name+")", value)
+		}
+		return s
+	}
+
+	var (
+		domains []string
+		seen    = map[string]bool{}
+	)
+	for _, d := range strings.Fields(expand(macros["primary_domain"] + " " + macros["local_domains"])) {
+		d = strings.ToLower(d)
+		if !seen[d] {
+			seen[d] = true
+			domains = append(domains, d)
+		}
+	}
+
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("no $(primary_domain) or $(local_domains) in maddy config")
+	}
+
+	return domains, nil
+}
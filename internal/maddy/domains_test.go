@@ -0,0 +1,31 @@
+package maddy
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseDomains(t *testing.T) {
+	conf := `## Maddy Mail Server - default configuration file
+
+$(hostname) = mx1.example.org
+$(primary_domain) = example.org
+$(local_domains) = $(primary_domain) example.net Example.org
+
+tls file /etc/maddy/certs/$(hostname)/fullchain.pem /etc/maddy/certs/$(hostname)/privkey.pem
+`
+
+	got, err := ParseDomains(strings.NewReader(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"example.org", "example.net"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := ParseDomains(strings.NewReader("$(hostname) = mx1\n")); err == nil {
+		t.Error("expected an error without domains")
+	}
+}
@@ -128,6 +128,12 @@ func (c ConfigParameters) Group() string {
 	return defaultGroup
 }
 
+// CertificateFile is the certificate chain maddy serves for Hostname, found
+// through the certs link that Config creates into the Let's Encrypt tree.
+func (c ConfigParameters) CertificateFile() string {
+	return filepath.Join(c.Root(), "etc/maddy/certs", c.Hostname, "fullchain.pem")
+}
+
 func Install(ctx context.Context, params InstallParameters) error {
 	if params.ForceCompile {
 		return buildAndInstall(ctx, params)
@@ -441,8 +447,7 @@ func buildAndInstall(ctx context.Context, params InstallParameters) error {
 }
 
 func configureDomains(ctx context.Context, params ConfigParameters) error {
-	root := params.Root()
-	confFile := filepath.Join(root, "etc/maddy/maddy.conf")
+	confFile := params.ConfigFile()
 
 	additionalDomains := ""
 	for _, a := range params.AdditionalDomains {
@@ -0,0 +1,121 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/imds"
+)
+
+func init() {
+	Register(Cloud{
+		Name:        "aws",
+		Description: "Amazon EC2 instance metadata service (IMDSv2)",
+		Detect: func(dmi DMI) bool {
+			// Xen based instances report the hypervisor vendor instead.
+			return vendorIs(dmi.SysVendor, "Amazon EC2") || strings.Contains(strings.ToLower(dmi.BIOSVersion), "amazon")
+		},
+		Factory: func(cfg Config) Provider {
+			return NewAWS(imds.NewClient(imds.WithV1Fallback(cfg.IMDSv1Fallback)))
+		},
+	})
+}
+
+type AWS struct {
+	client *imds.Client
+}
+
+func NewAWS(client *imds.Client) *AWS {
+	return &AWS{client: client}
+}
+
+func (a *AWS) Name() string { return "aws" }
+
+func (a *AWS) Instance(ctx context.Context) (*InstanceInfo, error) {
+	results := a.client.GetMetadataBatch(ctx,
+		"instance-id",
+		"instance-type",
+		"placement/region",
+		"placement/availability-zone",
+		"hostname",
+	)
+
+	values := make([]string, len(results))
+	for i, r := range results {
+		if r.Err != nil && !errors.Is(r.Err, imds.ErrNotFound) {
+			return nil, fmt.Errorf("getting %s: %w", r.Path, r.Err)
+		}
+		values[i] = r.Value
+	}
+
+	info := &InstanceInfo{
+		Cloud:        a.Name(),
+		InstanceID:   values[0],
+		InstanceType: values[1],
+		Region:       values[2],
+		Zone:         values[3],
+		Hostname:     values[4],
+	}
+
+	addrs, err := a.Addresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info.PublicIPv4, info.LocalIPv4, info.IPv6 = addrs.PublicIPv4, addrs.LocalIPv4, addrs.IPv6
+
+	if info.Tags, err = a.tags(ctx); err != nil {
+		return nil, err
+	}
+
+	userData, err := a.client.GetUserData(ctx)
+	if err != nil && !errors.Is(err, imds.ErrNotFound) {
+		return nil, fmt.Errorf("getting user data: %w", err)
+	}
+	info.UserData = userData
+
+	return info, nil
+}
+
+func (a *AWS) Addresses(ctx context.Context) (*Addresses, error) {
+	results := a.client.GetMetadataBatch(ctx, "public-ipv4", "local-ipv4")
+	for _, r := range results {
+		if r.Err != nil && !errors.Is(r.Err, imds.ErrNotFound) {
+			return nil, fmt.Errorf("getting %s: %w", r.Path, r.Err)
+		}
+	}
+
+	ipv6, err := a.client.GetIPv6(ctx)
+	if err != nil && !errors.Is(err, imds.ErrNotFound) {
+		return nil, fmt.Errorf("getting ipv6: %w", err)
+	}
+
+	return &Addresses{PublicIPv4: results[0].Value, LocalIPv4: results[1].Value, IPv6: ipv6}, nil
+}
+
+// tags returns the instance tags, which are only present when access to
+// tags in instance metadata is enabled.
+func (a *AWS) tags(ctx context.Context) (map[string]string, error) {
+	keys, err := a.client.ListMetadataPaths(ctx, "tags/instance")
+	if errors.Is(err, imds.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+
+	paths := make([]string, len(keys))
+	for i, k := range keys {
+		paths[i] = "tags/instance/" + k
+	}
+
+	tags := make(map[string]string, len(keys))
+	for i, r := range a.client.GetMetadataBatch(ctx, paths...) {
+		if r.Err != nil {
+			return nil, fmt.Errorf("getting tag %s: %w", keys[i], r.Err)
+		}
+		tags[keys[i]] = r.Value
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,40 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/imds"
+)
+
+func TestAWS_Instance(t *testing.T) {
+	server := serve(t, [2]string{}, map[string]string{
+		"/latest/api/token":                             "test-token",
+		"/latest/meta-data/instance-id":                 "i-1234567890abcdef0",
+		"/latest/meta-data/instance-type":               "t4g.small",
+		"/latest/meta-data/placement/region":            "us-west-2",
+		"/latest/meta-data/placement/availability-zone": "us-west-2a",
+		"/latest/meta-data/hostname":                    "ip-10-0-0-5.us-west-2.compute.internal",
+		"/latest/meta-data/local-ipv4":                  "10.0.0.5",
+		"/latest/meta-data/ipv6":                        "2600:1f14::5",
+		"/latest/meta-data/tags/instance":               "Name\nrole",
+		"/latest/meta-data/tags/instance/Name":          "mx1",
+		"/latest/meta-data/tags/instance/role":          "mail",
+	})
+
+	client := imds.NewClient(imds.WithEndpoint(server.URL), imds.WithHTTPClient(server.Client()))
+	info, err := NewAWS(client).Instance(context.Background())
+
+	// No public IPv4 and no user data are assigned.
+	checkInstance(t, info, err, &InstanceInfo{
+		Cloud:        "aws",
+		InstanceID:   "i-1234567890abcdef0",
+		InstanceType: "t4g.small",
+		Region:       "us-west-2",
+		Zone:         "us-west-2a",
+		Hostname:     "ip-10-0-0-5.us-west-2.compute.internal",
+		LocalIPv4:    "10.0.0.5",
+		IPv6:         "2600:1f14::5",
+		Tags:         map[string]string{"Name": "mx1", "role": "mail"},
+	})
+}
@@ -0,0 +1,143 @@
+package metadata
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+const DefaultAzureEndpoint = "http://169.254.169.254/metadata"
+
+// azureAssetTag is the chassis asset tag of every Azure VM.
+const azureAssetTag = "7783-7084-3265-9085-8269-3286-77"
+
+func init() {
+	Register(Cloud{
+		Name:        "azure",
+		Description: "Azure Instance Metadata Service",
+		Detect: func(dmi DMI) bool {
+			return dmi.ChassisAssetTag == azureAssetTag ||
+				(vendorIs(dmi.SysVendor, "Microsoft Corporation") && vendorIs(dmi.ProductName, "Virtual Machine"))
+		},
+		Factory: func(Config) Provider { return NewAzure() },
+	})
+}
+
+type Azure struct {
+	service *Service
+}
+
+func NewAzure(options ...func(*Service)) *Azure {
+	header := http.Header{"Metadata": {"true"}}
+	return &Azure{service: newService(DefaultAzureEndpoint, header, options...)}
+}
+
+func (a *Azure) Name() string { return "azure" }
+
+type azureIPAddress struct {
+	PrivateIPAddress string `json:"privateIpAddress"`
+	PublicIPAddress  string `json:"publicIpAddress"`
+}
+
+type azureInstance struct {
+	Compute struct {
+		VMID      string `json:"vmId"`
+		VMSize    string `json:"vmSize"`
+		Location  string `json:"location"`
+		Zone      string `json:"zone"`
+		Name      string `json:"name"`
+		OSProfile struct {
+			ComputerName string `json:"computerName"`
+		} `json:"osProfile"`
+		TagsList []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"tagsList"`
+		UserData string `json:"userData"`
+	} `json:"compute"`
+	Network azureNetwork `json:"network"`
+}
+
+type azureNetwork struct {
+	Interface []struct {
+		IPv4 struct {
+			IPAddress []azureIPAddress `json:"ipAddress"`
+		} `json:"ipv4"`
+		IPv6 struct {
+			IPAddress []azureIPAddress `json:"ipAddress"`
+		} `json:"ipv6"`
+	} `json:"interface"`
+}
+
+func (a *Azure) Instance(ctx context.Context) (*InstanceInfo, error) {
+	var inst azureInstance
+	if err := a.service.getJSON(ctx, "instance?api-version=2021-02-01", &inst); err != nil {
+		return nil, err
+	}
+
+	c := inst.Compute
+
+	info := &InstanceInfo{
+		Cloud:        a.Name(),
+		InstanceID:   c.VMID,
+		InstanceType: c.VMSize,
+		Region:       c.Location,
+		Zone:         c.Zone,
+		Hostname:     c.OSProfile.ComputerName,
+	}
+
+	if info.Hostname == "" {
+		info.Hostname = c.Name
+	}
+
+	if len(c.TagsList) > 0 {
+		info.Tags = map[string]string{}
+		for _, t := range c.TagsList {
+			info.Tags[t.Name] = t.Value
+		}
+	}
+
+	if c.UserData != "" {
+		data, err := base64.StdEncoding.DecodeString(c.UserData)
+		if err != nil {
+			return nil, fmt.Errorf("decoding user data: %w", err)
+		}
+		info.UserData = string(data)
+	}
+
+	addrs := inst.Network.addresses()
+	info.PublicIPv4, info.LocalIPv4, info.IPv6 = addrs.PublicIPv4, addrs.LocalIPv4, addrs.IPv6
+
+	return info, nil
+}
+
+func (a *Azure) Addresses(ctx context.Context) (*Addresses, error) {
+	var network azureNetwork
+	if err := a.service.getJSON(ctx, "instance/network?api-version=2021-02-01", &network); err != nil {
+		return nil, err
+	}
+
+	return network.addresses(), nil
+}
+
+// addresses reads the addresses of the first network interface.
+func (n azureNetwork) addresses() *Addresses {
+	addrs := &Addresses{}
+	if len(n.Interface) == 0 {
+		return addrs
+	}
+
+	nic := n.Interface[0]
+
+	if len(nic.IPv4.IPAddress) > 0 {
+		addrs.LocalIPv4 = nic.IPv4.IPAddress[0].PrivateIPAddress
+		addrs.PublicIPv4 = nic.IPv4.IPAddress[0].PublicIPAddress
+	}
+
+	if len(nic.IPv6.IPAddress) > 0 {
+		addrs.IPv6 = nic.IPv6.IPAddress[0].PrivateIPAddress
+	}
+
+	return addrs
+}
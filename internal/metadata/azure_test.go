@@ -0,0 +1,47 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+)
+
+const azureInstanceJSON = `{
+  "compute": {
+    "vmId": "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+    "vmSize": "Standard_B1s",
+    "location": "westeurope",
+    "zone": "2",
+    "name": "mx1",
+    "osProfile": {"computerName": "mx1"},
+    "tagsList": [{"name": "role", "value": "mail"}],
+    "userData": "I2Nsb3VkLWNvbmZpZwo="
+  },
+  "network": {
+    "interface": [{
+      "ipv4": {"ipAddress": [{"privateIpAddress": "10.0.0.4", "publicIpAddress": "20.50.1.2"}]},
+      "ipv6": {"ipAddress": [{"privateIpAddress": "ace:cab:deca::4"}]}
+    }]
+  }
+}`
+
+func TestAzure_Instance(t *testing.T) {
+	server := serve(t, [2]string{"Metadata", "true"}, map[string]string{
+		"/metadata/instance?api-version=2021-02-01": azureInstanceJSON,
+	})
+
+	info, err := NewAzure(WithEndpoint(server.URL + "/metadata")).Instance(context.Background())
+
+	checkInstance(t, info, err, &InstanceInfo{
+		Cloud:        "azure",
+		InstanceID:   "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+		InstanceType: "Standard_B1s",
+		Region:       "westeurope",
+		Zone:         "2",
+		Hostname:     "mx1",
+		PublicIPv4:   "20.50.1.2",
+		LocalIPv4:    "10.0.0.4",
+		IPv6:         "ace:cab:deca::4",
+		Tags:         map[string]string{"role": "mail"},
+		UserData:     "#cloud-config\n",
+	})
+}
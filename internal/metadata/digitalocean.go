@@ -0,0 +1,102 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const DefaultDigitalOceanEndpoint = "http://169.254.169.254/metadata"
+
+func init() {
+	Register(Cloud{
+		Name:        "digitalocean",
+		Description: "DigitalOcean droplet metadata service",
+		Detect: func(dmi DMI) bool {
+			return vendorIs(dmi.SysVendor, "DigitalOcean")
+		},
+		Factory: func(Config) Provider { return NewDigitalOcean() },
+	})
+}
+
+type DigitalOcean struct {
+	service *Service
+}
+
+func NewDigitalOcean(options ...func(*Service)) *DigitalOcean {
+	return &DigitalOcean{service: newService(DefaultDigitalOceanEndpoint, nil, options...)}
+}
+
+func (d *DigitalOcean) Name() string { return "digitalocean" }
+
+type doInterface struct {
+	IPv4 *struct {
+		IPAddress string `json:"ip_address"`
+	} `json:"ipv4"`
+	IPv6 *struct {
+		IPAddress string `json:"ip_address"`
+	} `json:"ipv6"`
+}
+
+type doDroplet struct {
+	DropletID  json.Number `json:"droplet_id"`
+	Hostname   string      `json:"hostname"`
+	Region     string      `json:"region"`
+	Tags       []string    `json:"tags"`
+	UserData   string      `json:"user_data"`
+	Interfaces struct {
+		Public  []doInterface `json:"public"`
+		Private []doInterface `json:"private"`
+	} `json:"interfaces"`
+}
+
+func (d *DigitalOcean) Instance(ctx context.Context) (*InstanceInfo, error) {
+	var drop doDroplet
+	if err := d.service.getJSON(ctx, "v1.json", &drop); err != nil {
+		return nil, err
+	}
+
+	info := &InstanceInfo{
+		Cloud:      d.Name(),
+		InstanceID: drop.DropletID.String(),
+		Region:     drop.Region,
+		Hostname:   drop.Hostname,
+		Tags:       tagSet(drop.Tags),
+		UserData:   drop.UserData,
+	}
+
+	if len(drop.Interfaces.Public) > 0 {
+		nic := drop.Interfaces.Public[0]
+		if nic.IPv4 != nil {
+			info.PublicIPv4 = nic.IPv4.IPAddress
+		}
+		if nic.IPv6 != nil {
+			info.IPv6 = nic.IPv6.IPAddress
+		}
+	}
+
+	if len(drop.Interfaces.Private) > 0 && drop.Interfaces.Private[0].IPv4 != nil {
+		info.LocalIPv4 = drop.Interfaces.Private[0].IPv4.IPAddress
+	}
+
+	return info, nil
+}
+
+// Addresses reads the per-value paths rather than v1.json, which also
+// holds the user data.
+func (d *DigitalOcean) Addresses(ctx context.Context) (*Addresses, error) {
+	addrs := &Addresses{}
+
+	for path, value := range map[string]*string{
+		"v1/interfaces/public/0/ipv4/address":  &addrs.PublicIPv4,
+		"v1/interfaces/public/0/ipv6/address":  &addrs.IPv6,
+		"v1/interfaces/private/0/ipv4/address": &addrs.LocalIPv4,
+	} {
+		v, err := d.service.getOptional(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		*value = v
+	}
+
+	return addrs, nil
+}
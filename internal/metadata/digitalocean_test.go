@@ -0,0 +1,45 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+)
+
+const doDropletJSON = `{
+  "droplet_id": 2756294,
+  "hostname": "mx1.example.com",
+  "region": "ams3",
+  "tags": ["mail"],
+  "user_data": "#cloud-config\n",
+  "interfaces": {
+    "public": [{
+      "ipv4": {"ip_address": "203.0.113.10", "netmask": "255.255.240.0", "gateway": "203.0.113.1"},
+      "ipv6": {"ip_address": "2a03:b0c0:2:d0::1", "cidr": 64, "gateway": "2a03:b0c0:2:d0::1:1"},
+      "type": "public"
+    }],
+    "private": [{
+      "ipv4": {"ip_address": "10.110.0.2", "netmask": "255.255.240.0", "gateway": "0.0.0.0"},
+      "type": "private"
+    }]
+  }
+}`
+
+func TestDigitalOcean_Instance(t *testing.T) {
+	server := serve(t, [2]string{}, map[string]string{
+		"/metadata/v1.json": doDropletJSON,
+	})
+
+	info, err := NewDigitalOcean(WithEndpoint(server.URL + "/metadata")).Instance(context.Background())
+
+	checkInstance(t, info, err, &InstanceInfo{
+		Cloud:      "digitalocean",
+		InstanceID: "2756294",
+		Region:     "ams3",
+		Hostname:   "mx1.example.com",
+		PublicIPv4: "203.0.113.10",
+		LocalIPv4:  "10.110.0.2",
+		IPv6:       "2a03:b0c0:2:d0::1",
+		Tags:       map[string]string{"mail": ""},
+		UserData:   "#cloud-config\n",
+	})
+}
@@ -0,0 +1,116 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const DefaultGCPEndpoint = "http://metadata.google.internal/computeMetadata/v1"
+
+func init() {
+	Register(Cloud{
+		Name:        "gcp",
+		Description: "Google Compute Engine metadata server",
+		Detect: func(dmi DMI) bool {
+			return vendorIs(dmi.SysVendor, "Google") || vendorIs(dmi.ProductName, "Google Compute Engine")
+		},
+		Factory: func(Config) Provider { return NewGCP() },
+	})
+}
+
+type GCP struct {
+	service *Service
+}
+
+func NewGCP(options ...func(*Service)) *GCP {
+	header := http.Header{"Metadata-Flavor": {"Google"}}
+	return &GCP{service: newService(DefaultGCPEndpoint, header, options...)}
+}
+
+func (g *GCP) Name() string { return "gcp" }
+
+type gcpInstance struct {
+	ID                json.Number           `json:"id"`
+	Hostname          string                `json:"hostname"`
+	MachineType       string                `json:"machineType"`
+	Zone              string                `json:"zone"`
+	Tags              []string              `json:"tags"`
+	Attributes        map[string]string     `json:"attributes"`
+	NetworkInterfaces []gcpNetworkInterface `json:"networkInterfaces"`
+}
+
+type gcpNetworkInterface struct {
+	IP            string   `json:"ip"`
+	IPv6s         []string `json:"ipv6s"`
+	AccessConfigs []struct {
+		ExternalIP string `json:"externalIp"`
+	} `json:"accessConfigs"`
+	IPv6AccessConfigs []struct {
+		ExternalIPv6 string `json:"externalIpv6"`
+	} `json:"ipv6AccessConfigs"`
+}
+
+func (g *GCP) Instance(ctx context.Context) (*InstanceInfo, error) {
+	var inst gcpInstance
+	if err := g.service.getJSON(ctx, "instance/?recursive=true", &inst); err != nil {
+		return nil, err
+	}
+
+	// Machine type and zone are resource paths such as
+	// projects/123/zones/us-central1-a.
+	zone := path.Base(inst.Zone)
+
+	info := &InstanceInfo{
+		Cloud:        g.Name(),
+		InstanceID:   inst.ID.String(),
+		InstanceType: path.Base(inst.MachineType),
+		Zone:         zone,
+		Hostname:     inst.Hostname,
+		Tags:         tagSet(inst.Tags),
+		UserData:     inst.Attributes["user-data"],
+	}
+
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		info.Region = zone[:i]
+	}
+
+	addrs := gcpAddresses(inst.NetworkInterfaces)
+	info.PublicIPv4, info.LocalIPv4, info.IPv6 = addrs.PublicIPv4, addrs.LocalIPv4, addrs.IPv6
+
+	return info, nil
+}
+
+func (g *GCP) Addresses(ctx context.Context) (*Addresses, error) {
+	var nics []gcpNetworkInterface
+	if err := g.service.getJSON(ctx, "instance/network-interfaces/?recursive=true", &nics); err != nil {
+		return nil, err
+	}
+
+	return gcpAddresses(nics), nil
+}
+
+// gcpAddresses reads the addresses of the first network interface.
+func gcpAddresses(nics []gcpNetworkInterface) *Addresses {
+	addrs := &Addresses{}
+	if len(nics) == 0 {
+		return addrs
+	}
+
+	nic := nics[0]
+	addrs.LocalIPv4 = nic.IP
+
+	if len(nic.AccessConfigs) > 0 {
+		addrs.PublicIPv4 = nic.AccessConfigs[0].ExternalIP
+	}
+
+	if len(nic.IPv6AccessConfigs) > 0 {
+		addrs.IPv6 = nic.IPv6AccessConfigs[0].ExternalIPv6
+	} else if len(nic.IPv6s) > 0 {
+		addrs.IPv6 = nic.IPv6s[0]
+	}
+
+	return addrs
+}
@@ -0,0 +1,42 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+)
+
+const gcpInstanceJSON = `{
+  "id": 4520031799277581759,
+  "hostname": "mx1.c.example.internal",
+  "machineType": "projects/123456789/machineTypes/e2-small",
+  "zone": "projects/123456789/zones/us-central1-a",
+  "tags": ["mail", "smtp"],
+  "attributes": {"user-data": "#cloud-config\n"},
+  "networkInterfaces": [{
+    "ip": "10.128.0.2",
+    "accessConfigs": [{"externalIp": "34.121.0.10", "type": "ONE_TO_ONE_NAT"}],
+    "ipv6AccessConfigs": [{"externalIpv6": "2600:1900:4000:1::"}]
+  }]
+}`
+
+func TestGCP_Instance(t *testing.T) {
+	server := serve(t, [2]string{"Metadata-Flavor", "Google"}, map[string]string{
+		"/computeMetadata/v1/instance/?recursive=true": gcpInstanceJSON,
+	})
+
+	info, err := NewGCP(WithEndpoint(server.URL + "/computeMetadata/v1")).Instance(context.Background())
+
+	checkInstance(t, info, err, &InstanceInfo{
+		Cloud:        "gcp",
+		InstanceID:   "4520031799277581759",
+		InstanceType: "e2-small",
+		Region:       "us-central1",
+		Zone:         "us-central1-a",
+		Hostname:     "mx1.c.example.internal",
+		PublicIPv4:   "34.121.0.10",
+		LocalIPv4:    "10.128.0.2",
+		IPv6:         "2600:1900:4000:1::",
+		Tags:         map[string]string{"mail": "", "smtp": ""},
+		UserData:     "#cloud-config\n",
+	})
+}
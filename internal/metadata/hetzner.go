@@ -0,0 +1,119 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const DefaultHetznerEndpoint = "http://169.254.169.254/hetzner/v1"
+
+func init() {
+	Register(Cloud{
+		Name:        "hetzner",
+		Description: "Hetzner Cloud metadata service",
+		Detect: func(dmi DMI) bool {
+			return vendorIs(dmi.SysVendor, "Hetzner")
+		},
+		Factory: func(Config) Provider { return NewHetzner() },
+	})
+}
+
+type Hetzner struct {
+	service *Service
+}
+
+func NewHetzner(options ...func(*Service)) *Hetzner {
+	return &Hetzner{service: newService(DefaultHetznerEndpoint, nil, options...)}
+}
+
+func (h *Hetzner) Name() string { return "hetzner" }
+
+// hetznerNetworkConfig is the cloud-init network config v1 Hetzner
+// serves, which is the only place the IPv6 address is listed.
+type hetznerNetworkConfig struct {
+	Config []struct {
+		Subnets []struct {
+			IPv6    bool   `yaml:"ipv6"`
+			Address string `yaml:"address"`
+		} `yaml:"subnets"`
+	} `yaml:"config"`
+}
+
+type hetznerPrivateNetwork struct {
+	IP string `yaml:"ip"`
+}
+
+func (h *Hetzner) Instance(ctx context.Context) (*InstanceInfo, error) {
+	info := &InstanceInfo{Cloud: h.Name()}
+
+	for path, value := range map[string]*string{
+		"metadata/instance-id":       &info.InstanceID,
+		"metadata/hostname":          &info.Hostname,
+		"metadata/region":            &info.Region,
+		"metadata/availability-zone": &info.Zone,
+		"userdata":                   &info.UserData,
+	} {
+		v, err := h.service.getOptional(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		*value = v
+	}
+
+	addrs, err := h.Addresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info.PublicIPv4, info.LocalIPv4, info.IPv6 = addrs.PublicIPv4, addrs.LocalIPv4, addrs.IPv6
+
+	return info, nil
+}
+
+func (h *Hetzner) Addresses(ctx context.Context) (*Addresses, error) {
+	addrs := &Addresses{}
+
+	var err error
+	if addrs.PublicIPv4, err = h.service.getOptional(ctx, "metadata/public-ipv4"); err != nil {
+		return nil, err
+	}
+
+	var nc hetznerNetworkConfig
+	if err = h.getYAML(ctx, "metadata/network-config", &nc); err != nil {
+		return nil, err
+	}
+
+	for _, c := range nc.Config {
+		for _, s := range c.Subnets {
+			if s.IPv6 && addrs.IPv6 == "" {
+				addrs.IPv6, _, _ = strings.Cut(s.Address, "/")
+			}
+		}
+	}
+
+	var networks []hetznerPrivateNetwork
+	if err = h.getYAML(ctx, "metadata/private-networks", &networks); err != nil {
+		return nil, err
+	}
+
+	if len(networks) > 0 {
+		addrs.LocalIPv4 = networks[0].IP
+	}
+
+	return addrs, nil
+}
+
+func (h *Hetzner) getYAML(ctx context.Context, path string, v any) error {
+	body, err := h.service.getOptional(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if err = yaml.Unmarshal([]byte(body), v); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return nil
+}
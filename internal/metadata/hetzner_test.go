@@ -0,0 +1,56 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+)
+
+const hetznerNetworkConfigYAML = `config:
+- mac_address: 96:00:01:02:03:04
+  name: eth0
+  subnets:
+  - ipv4: true
+    type: dhcp
+  - address: 2a01:4f8:c17:1234::1/64
+    gateway: fe80::1
+    ipv6: true
+    type: static
+  type: physical
+version: 1
+`
+
+const hetznerPrivateNetworksYAML = `- ip: 10.0.0.2
+  alias_ips: []
+  interface_num: 1
+  mac_address: 86:00:00:01:02:03
+  network_id: 1234
+  network_name: internal
+  network: 10.0.0.0/16
+  subnet: 10.0.0.0/24
+  gateway: 10.0.0.1
+`
+
+func TestHetzner_Instance(t *testing.T) {
+	server := serve(t, [2]string{}, map[string]string{
+		"/hetzner/v1/metadata/instance-id":       "42424242",
+		"/hetzner/v1/metadata/hostname":          "mx1",
+		"/hetzner/v1/metadata/region":            "eu-central",
+		"/hetzner/v1/metadata/availability-zone": "fsn1-dc14",
+		"/hetzner/v1/metadata/public-ipv4":       "198.51.100.7",
+		"/hetzner/v1/metadata/network-config":    hetznerNetworkConfigYAML,
+		"/hetzner/v1/metadata/private-networks":  hetznerPrivateNetworksYAML,
+	})
+
+	info, err := NewHetzner(WithEndpoint(server.URL + "/hetzner/v1")).Instance(context.Background())
+
+	checkInstance(t, info, err, &InstanceInfo{
+		Cloud:      "hetzner",
+		InstanceID: "42424242",
+		Region:     "eu-central",
+		Zone:       "fsn1-dc14",
+		Hostname:   "mx1",
+		PublicIPv4: "198.51.100.7",
+		LocalIPv4:  "10.0.0.2",
+		IPv6:       "2a01:4f8:c17:1234::1",
+	})
+}
@@ -0,0 +1,186 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownCloud = errors.New("unknown cloud")
+	ErrNotDetected  = errors.New("cloud not detected")
+	ErrNotFound     = errors.New("metadata not found")
+)
+
+// DefaultDMIPath is where Linux exposes the SMBIOS strings used to detect
+// the cloud.
+const DefaultDMIPath = "/sys/class/dmi/id"
+
+// InstanceInfo is the instance metadata common to all clouds. Values a
+// cloud does not expose are left empty.
+type InstanceInfo struct {
+	Cloud        string            `json:"cloud"`
+	InstanceID   string            `json:"instance_id"`
+	InstanceType string            `json:"instance_type,omitempty"`
+	Region       string            `json:"region,omitempty"`
+	Zone         string            `json:"zone,omitempty"`
+	Hostname     string            `json:"hostname,omitempty"`
+	PublicIPv4   string            `json:"public_ipv4,omitempty"`
+	LocalIPv4    string            `json:"local_ipv4,omitempty"`
+	IPv6         string            `json:"ipv6,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	UserData     string            `json:"user_data,omitempty"`
+}
+
+// Addresses are the IP addresses of the instance, as in InstanceInfo.
+type Addresses struct {
+	PublicIPv4 string `json:"public_ipv4,omitempty"`
+	LocalIPv4  string `json:"local_ipv4,omitempty"`
+	IPv6       string `json:"ipv6,omitempty"`
+}
+
+// Provider reads instance metadata from a cloud's metadata service.
+// Addresses reads only what is needed for the addresses, for callers that
+// poll them.
+type Provider interface {
+	Name() string
+	Instance(ctx context.Context) (*InstanceInfo, error)
+	Addresses(ctx context.Context) (*Addresses, error)
+}
+
+// Config holds the settings some providers accept.
+type Config struct {
+	// IMDSv1Fallback lets the AWS provider make requests without a token.
+	IMDSv1Fallback bool
+
+	// ConfigDrive is the directory an OpenStack config drive is mounted
+	// on. The metadata service is used when it is empty.
+	ConfigDrive string
+}
+
+type Factory func(Config) Provider
+
+// Cloud is a registered metadata provider.
+type Cloud struct {
+	Name        string
+	Description string
+	Detect      func(DMI) bool
+	Factory     Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Cloud{}
+)
+
+// Register makes a cloud available by name. It panics if the name is
+// empty, has no factory or detector, or is already registered.
+func Register(c Cloud) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if c.Name == "" || c.Factory == nil || c.Detect == nil {
+		panic("metadata: Register called with incomplete cloud")
+	}
+
+	if _, dup := registry[c.Name]; dup {
+		panic("metadata: Register called twice for cloud " + c.Name)
+	}
+
+	registry[c.Name] = c
+}
+
+// Clouds returns the registered clouds sorted by name.
+func Clouds() []Cloud {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var res []Cloud
+	for _, c := range registry {
+		res = append(res, c)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res
+}
+
+// New builds the provider of the named cloud.
+func New(name string, cfg Config) (Provider, error) {
+	registryMu.RLock()
+	c, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCloud, name)
+	}
+
+	return c.Factory(cfg), nil
+}
+
+// Detect returns the name of the cloud the DMI strings belong to.
+func Detect(dmi DMI) (string, error) {
+	for _, c := range Clouds() {
+		if c.Detect(dmi) {
+			return c.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: vendor %q, product %q", ErrNotDetected, dmi.SysVendor, dmi.ProductName)
+}
+
+// DMI holds the SMBIOS strings clouds identify themselves with.
+type DMI struct {
+	SysVendor       string
+	ProductName     string
+	ProductVersion  string
+	BIOSVendor      string
+	BIOSVersion     string
+	ChassisAssetTag string
+}
+
+// ReadDMI reads the DMI strings from dir, usually DefaultDMIPath. Missing
+// entries are left empty.
+func ReadDMI(dir string) (DMI, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return DMI{}, fmt.Errorf("reading dmi: %w", err)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	}
+
+	return DMI{
+		SysVendor:       read("sys_vendor"),
+		ProductName:     read("product_name"),
+		ProductVersion:  read("product_version"),
+		BIOSVendor:      read("bios_vendor"),
+		BIOSVersion:     read("bios_version"),
+		ChassisAssetTag: read("chassis_asset_tag"),
+	}, nil
+}
+
+// DetectProvider detects the cloud from the DMI strings in dir and builds
+// its provider.
+func DetectProvider(dir string, cfg Config) (Provider, error) {
+	dmi, err := ReadDMI(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := Detect(dmi)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(name, cfg)
+}
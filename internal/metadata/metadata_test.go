@@ -0,0 +1,196 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tempusbreve/cloud-init-helper/internal/imds"
+)
+
+// serve answers the request URIs in routes and 404 for anything else. It
+// fails requests that lack the header given as key and value.
+func serve(t *testing.T, header [2]string, routes map[string]string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header[0] != "" && r.Header.Get(header[0]) != header[1] {
+			t.Errorf("%s: expected header %s: %s", r.URL, header[0], header[1])
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		body, ok := routes[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func checkInstance(t *testing.T, got *InstanceInfo, err error, want *InstanceInfo) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected instance info\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		dmi  DMI
+		want string
+	}{
+		{DMI{SysVendor: "Amazon EC2", ProductName: "t4g.small"}, "aws"},
+		{DMI{SysVendor: "Xen", BIOSVersion: "4.11.amazon"}, "aws"},
+		{DMI{SysVendor: "Google", ProductName: "Google Compute Engine"}, "gcp"},
+		{DMI{SysVendor: "Microsoft Corporation", ProductName: "Virtual Machine"}, "azure"},
+		{DMI{ChassisAssetTag: "7783-7084-3265-9085-8269-3286-77"}, "azure"},
+		{DMI{SysVendor: "DigitalOcean", ProductName: "Droplet"}, "digitalocean"},
+		{DMI{SysVendor: "Hetzner", ProductName: "vServer"}, "hetzner"},
+		{DMI{SysVendor: "OpenStack Foundation", ProductName: "OpenStack Nova"}, "openstack"},
+	}
+
+	for _, tt := range tests {
+		got, err := Detect(tt.dmi)
+		if err != nil || got != tt.want {
+			t.Errorf("%+v: expected %s, got %q, %v", tt.dmi, tt.want, got, err)
+		}
+	}
+
+	if _, err := Detect(DMI{SysVendor: "QEMU", ProductName: "Standard PC"}); !errors.Is(err, ErrNotDetected) {
+		t.Errorf("Expected ErrNotDetected, got %v", err)
+	}
+}
+
+func TestReadDMI(t *testing.T) {
+	dir := t.TempDir()
+	for name, value := range map[string]string{
+		"sys_vendor":   "Hetzner\n",
+		"product_name": "vServer\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dmi, err := ReadDMI(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if want := (DMI{SysVendor: "Hetzner", ProductName: "vServer"}); dmi != want {
+		t.Errorf("Expected %+v, got %+v", want, dmi)
+	}
+
+	p, err := DetectProvider(dir, Config{})
+	if err != nil || p.Name() != "hetzner" {
+		t.Errorf("Expected the hetzner provider, got %v, %v", p, err)
+	}
+
+	if _, err = ReadDMI(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected error for a missing DMI directory")
+	}
+
+	if _, err = New("vultr", Config{}); !errors.Is(err, ErrUnknownCloud) {
+		t.Errorf("Expected ErrUnknownCloud, got %v", err)
+	}
+}
+
+// TestProvider_Addresses serves only the paths each provider needs for the
+// addresses, so reading the full instance document would fail.
+func TestProvider_Addresses(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   [2]string
+		routes   map[string]string
+		provider func(endpoint string) Provider
+		want     Addresses
+	}{
+		{
+			name: "aws",
+			routes: map[string]string{
+				"/latest/api/token":             "test-token",
+				"/latest/meta-data/public-ipv4": "198.51.100.5",
+				"/latest/meta-data/local-ipv4":  "10.0.0.5",
+			},
+			provider: func(endpoint string) Provider { return NewAWS(imds.NewClient(imds.WithEndpoint(endpoint))) },
+			want:     Addresses{PublicIPv4: "198.51.100.5", LocalIPv4: "10.0.0.5"},
+		},
+		{
+			name:   "gcp",
+			header: [2]string{"Metadata-Flavor", "Google"},
+			routes: map[string]string{
+				"/instance/network-interfaces/?recursive=true": `[{"ip": "10.128.0.2", "accessConfigs": [{"externalIp": "34.121.0.10"}], "ipv6s": ["fd20::2"]}]`,
+			},
+			provider: func(endpoint string) Provider { return NewGCP(WithEndpoint(endpoint)) },
+			want:     Addresses{PublicIPv4: "34.121.0.10", LocalIPv4: "10.128.0.2", IPv6: "fd20::2"},
+		},
+		{
+			name:   "azure",
+			header: [2]string{"Metadata", "true"},
+			routes: map[string]string{
+				"/instance/network?api-version=2021-02-01": `{"interface": [{"ipv4": {"ipAddress": [{"privateIpAddress": "10.0.0.4", "publicIpAddress": "20.50.1.2"}]}}]}`,
+			},
+			provider: func(endpoint string) Provider { return NewAzure(WithEndpoint(endpoint)) },
+			want:     Addresses{PublicIPv4: "20.50.1.2", LocalIPv4: "10.0.0.4"},
+		},
+		{
+			name: "digitalocean",
+			routes: map[string]string{
+				"/v1/interfaces/public/0/ipv4/address":  "203.0.113.10",
+				"/v1/interfaces/public/0/ipv6/address":  "2a03:b0c0:2:d0::1",
+				"/v1/interfaces/private/0/ipv4/address": "10.110.0.2",
+			},
+			provider: func(endpoint string) Provider { return NewDigitalOcean(WithEndpoint(endpoint)) },
+			want:     Addresses{PublicIPv4: "203.0.113.10", LocalIPv4: "10.110.0.2", IPv6: "2a03:b0c0:2:d0::1"},
+		},
+		{
+			name: "hetzner",
+			routes: map[string]string{
+				"/metadata/public-ipv4":      "198.51.100.7",
+				"/metadata/network-config":   hetznerNetworkConfigYAML,
+				"/metadata/private-networks": hetznerPrivateNetworksYAML,
+			},
+			provider: func(endpoint string) Provider { return NewHetzner(WithEndpoint(endpoint)) },
+			want:     Addresses{PublicIPv4: "198.51.100.7", LocalIPv4: "10.0.0.2", IPv6: "2a01:4f8:c17:1234::1"},
+		},
+		{
+			name: "openstack",
+			routes: map[string]string{
+				"/openstack/latest/network_data.json": openStackNetworkDataJSON,
+				"/latest/meta-data/public-ipv4":       "203.0.113.20",
+			},
+			provider: func(endpoint string) Provider { return NewOpenStack(WithEndpoint(endpoint)) },
+			want:     Addresses{PublicIPv4: "203.0.113.20", LocalIPv4: "192.168.1.10", IPv6: "2001:db8::10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serve(t, tt.header, tt.routes)
+
+			got, err := tt.provider(server.URL).Addresses(context.Background())
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if *got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, *got)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+)
+
+const DefaultOpenStackEndpoint = "http://169.254.169.254"
+
+func init() {
+	Register(Cloud{
+		Name:        "openstack",
+		Description: "OpenStack metadata service or config drive",
+		Detect: func(dmi DMI) bool {
+			return vendorIs(dmi.SysVendor, "OpenStack Foundation") || vendorIs(dmi.ProductName, "OpenStack Nova", "OpenStack Compute")
+		},
+		Factory: func(cfg Config) Provider {
+			if cfg.ConfigDrive != "" {
+				return NewOpenStack(WithDirectory(cfg.ConfigDrive))
+			}
+			return NewOpenStack()
+		},
+	})
+}
+
+// OpenStack reads the openstack/latest documents, which the metadata
+// service and a config drive both provide. Instance type and public IPv4
+// come from the EC2 compatible metadata when it is available.
+type OpenStack struct {
+	service *Service
+}
+
+func NewOpenStack(options ...func(*Service)) *OpenStack {
+	return &OpenStack{service: newService(DefaultOpenStackEndpoint, nil, options...)}
+}
+
+func (o *OpenStack) Name() string { return "openstack" }
+
+type openStackMetadata struct {
+	UUID             string            `json:"uuid"`
+	Hostname         string            `json:"hostname"`
+	Name             string            `json:"name"`
+	AvailabilityZone string            `json:"availability_zone"`
+	Meta             map[string]string `json:"meta"`
+}
+
+type openStackNetworkData struct {
+	Networks []struct {
+		Type      string `json:"type"`
+		IPAddress string `json:"ip_address"`
+	} `json:"networks"`
+}
+
+type openStackEC2Metadata struct {
+	InstanceType string `json:"instance-type"`
+	PublicIPv4   string `json:"public-ipv4"`
+	LocalIPv4    string `json:"local-ipv4"`
+}
+
+func (o *OpenStack) Instance(ctx context.Context) (*InstanceInfo, error) {
+	var md openStackMetadata
+	if err := o.service.getJSON(ctx, "openstack/latest/meta_data.json", &md); err != nil {
+		return nil, err
+	}
+
+	info := &InstanceInfo{
+		Cloud:      o.Name(),
+		InstanceID: md.UUID,
+		Zone:       md.AvailabilityZone,
+		Hostname:   md.Hostname,
+	}
+
+	if info.Hostname == "" {
+		info.Hostname = md.Name
+	}
+
+	if len(md.Meta) > 0 {
+		info.Tags = md.Meta
+	}
+
+	addrs, err := o.Addresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info.PublicIPv4, info.LocalIPv4, info.IPv6 = addrs.PublicIPv4, addrs.LocalIPv4, addrs.IPv6
+
+	var ec2 openStackEC2Metadata
+	if err = o.ec2Metadata(ctx, &ec2, "instance-type"); err != nil {
+		return nil, err
+	}
+	info.InstanceType = ec2.InstanceType
+
+	if info.UserData, err = o.service.getOptional(ctx, "openstack/latest/user_data"); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (o *OpenStack) Addresses(ctx context.Context) (*Addresses, error) {
+	addrs := &Addresses{}
+
+	var nd openStackNetworkData
+	if err := o.service.getJSON(ctx, "openstack/latest/network_data.json", &nd); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	for _, n := range nd.Networks {
+		switch n.Type {
+		case "ipv4":
+			if addrs.LocalIPv4 == "" {
+				addrs.LocalIPv4 = n.IPAddress
+			}
+		case "ipv6":
+			if addrs.IPv6 == "" {
+				addrs.IPv6 = n.IPAddress
+			}
+		}
+	}
+
+	var ec2 openStackEC2Metadata
+	if err := o.ec2Metadata(ctx, &ec2, "public-ipv4", "local-ipv4"); err != nil {
+		return nil, err
+	}
+
+	addrs.PublicIPv4 = ec2.PublicIPv4
+	if addrs.LocalIPv4 == "" {
+		addrs.LocalIPv4 = ec2.LocalIPv4
+	}
+
+	return addrs, nil
+}
+
+// ec2Metadata reads the named EC2 compatible values into md. The config
+// drive has them in one JSON document, the metadata service has one path
+// per value.
+func (o *OpenStack) ec2Metadata(ctx context.Context, md *openStackEC2Metadata, names ...string) error {
+	if o.service.dir != "" {
+		err := o.service.getJSON(ctx, "ec2/latest/meta-data.json", md)
+		if errors.Is(err, ErrNotFound) {
+			err = nil
+		}
+		return err
+	}
+
+	values := map[string]*string{
+		"instance-type": &md.InstanceType,
+		"public-ipv4":   &md.PublicIPv4,
+		"local-ipv4":    &md.LocalIPv4,
+	}
+
+	for _, name := range names {
+		v, err := o.service.getOptional(ctx, "latest/meta-data/"+name)
+		if err != nil {
+			return err
+		}
+		*values[name] = v
+	}
+
+	return nil
+}
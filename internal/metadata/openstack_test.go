@@ -0,0 +1,78 @@
+package metadata
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const openStackMetadataJSON = `{
+  "uuid": "d8e02d56-2648-49a3-bf97-6be8f1204f38",
+  "name": "mx1",
+  "hostname": "mx1.novalocal",
+  "availability_zone": "nova",
+  "meta": {"role": "mail"},
+  "project_id": "f7ac731cc11f40efbc03a9f9e1d1d21f"
+}`
+
+const openStackNetworkDataJSON = `{
+  "networks": [
+    {"id": "network0", "type": "ipv4", "ip_address": "192.168.1.10", "netmask": "255.255.255.0"},
+    {"id": "network1", "type": "ipv6", "ip_address": "2001:db8::10", "netmask": "ffff:ffff:ffff:ffff::"}
+  ]
+}`
+
+func TestOpenStack_Instance(t *testing.T) {
+	want := &InstanceInfo{
+		Cloud:        "openstack",
+		InstanceID:   "d8e02d56-2648-49a3-bf97-6be8f1204f38",
+		InstanceType: "m1.small",
+		Zone:         "nova",
+		Hostname:     "mx1.novalocal",
+		PublicIPv4:   "203.0.113.20",
+		LocalIPv4:    "192.168.1.10",
+		IPv6:         "2001:db8::10",
+		Tags:         map[string]string{"role": "mail"},
+		UserData:     "#cloud-config",
+	}
+
+	t.Run("metadata service", func(t *testing.T) {
+		server := serve(t, [2]string{}, map[string]string{
+			"/openstack/latest/meta_data.json":    openStackMetadataJSON,
+			"/openstack/latest/network_data.json": openStackNetworkDataJSON,
+			"/openstack/latest/user_data":         "#cloud-config\n",
+			"/latest/meta-data/instance-type":     "m1.small",
+			"/latest/meta-data/public-ipv4":       "203.0.113.20",
+		})
+
+		info, err := NewOpenStack(WithEndpoint(server.URL)).Instance(context.Background())
+		checkInstance(t, info, err, want)
+	})
+
+	t.Run("config drive", func(t *testing.T) {
+		dir := t.TempDir()
+		for name, content := range map[string]string{
+			"openstack/latest/meta_data.json":    openStackMetadataJSON,
+			"openstack/latest/network_data.json": openStackNetworkDataJSON,
+			"openstack/latest/user_data":         "#cloud-config\n",
+			"ec2/latest/meta-data.json":          `{"instance-type": "m1.small", "public-ipv4": "203.0.113.20"}`,
+		} {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		p, err := New("openstack", Config{ConfigDrive: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := p.Instance(context.Background())
+		checkInstance(t, info, err, want)
+	})
+}
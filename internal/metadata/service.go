@@ -0,0 +1,140 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Service reads metadata documents from a metadata HTTP endpoint, or from
+// a directory such as a mounted config drive.
+type Service struct {
+	endpoint   string
+	dir        string
+	header     http.Header
+	httpClient *http.Client
+}
+
+// WithEndpoint replaces the base URL of the metadata service.
+func WithEndpoint(endpoint string) func(*Service) {
+	return func(s *Service) { s.endpoint = strings.TrimSuffix(endpoint, "/") }
+}
+
+// WithDirectory reads the documents from dir instead of the endpoint.
+func WithDirectory(dir string) func(*Service) {
+	return func(s *Service) { s.dir = dir }
+}
+
+func WithHTTPClient(client *http.Client) func(*Service) {
+	return func(s *Service) { s.httpClient = client }
+}
+
+func newService(endpoint string, header http.Header, options ...func(*Service)) *Service {
+	s := &Service{
+		endpoint:   endpoint,
+		header:     header,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+func (s *Service) get(ctx context.Context, path string) ([]byte, error) {
+	if s.dir != "" {
+		data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(path)))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+		}
+		return data, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.endpoint+"/"+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	for k, v := range s.header {
+		req.Header[k] = v
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: request failed with status %d", path, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	return body, nil
+}
+
+func (s *Service) getString(ctx context.Context, path string) (string, error) {
+	body, err := s.get(ctx, path)
+	return strings.TrimSpace(string(body)), err
+}
+
+// getOptional is getString for values that may not exist.
+func (s *Service) getOptional(ctx context.Context, path string) (string, error) {
+	value, err := s.getString(ctx, path)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	return value, err
+}
+
+func (s *Service) getJSON(ctx context.Context, path string, v any) error {
+	body, err := s.get(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func vendorIs(value string, vendors ...string) bool {
+	for _, v := range vendors {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagSet turns a list of tags without values into a map.
+func tagSet(tags []string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	m := make(map[string]string, len(tags))
+	for _, t := range tags {
+		m[t] = ""
+	}
+	return m
+}
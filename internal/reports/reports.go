@@ -0,0 +1,252 @@
+// Package reports reads the report documents that mail receivers send to
+// rua addresses, from a directory of files or from a maildir.
+package reports
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxPayload bounds decompressed reports, which come from untrusted senders.
+const maxPayload = 64 << 20
+
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// Payload is a single decompressed report document.
+type Payload struct {
+	// Source is the file the payload came from, followed by the attachment
+	// or archive member name.
+	Source string
+	Data   []byte
+
+	// Err is set, and Data empty, when the file could not be read or
+	// decoded.
+	Err error
+}
+
+// Read returns the report payloads found under path. Plain report files,
+// gzip and zip archives, and mail messages with report attachments are all
+// accepted; maildir tmp directories are skipped. A file that cannot be read
+// or decoded does not stop the others: it is returned as a payload with
+// Err set.
+func Read(path string) ([]Payload, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return readFile(path), nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() && d.Name() == "tmp" && isMaildir(filepath.Dir(p)) {
+			return filepath.SkipDir
+		}
+
+		if d.Type().IsRegular() && !strings.HasPrefix(d.Name(), ".") {
+			files = append(files, p)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	sort.Strings(files)
+
+	var res []Payload
+	for _, f := range files {
+		res = append(res, readFile(f)...)
+	}
+
+	return res, nil
+}
+
+func isMaildir(dir string) bool {
+	for _, sub := range []string{"cur", "new"} {
+		if info, err := os.Stat(filepath.Join(dir, sub)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+func readFile(path string) []Payload {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []Payload{{Source: path, Err: fmt.Errorf("reading report: %w", err)}}
+	}
+
+	payloads, err := Decode(path, data)
+	if err != nil {
+		return []Payload{{Source: path, Err: err}}
+	}
+
+	return payloads
+}
+
+// Decode extracts the report payloads from data, which is either a report
+// document, a gzip or zip archive of one, or a mail message carrying them.
+func Decode(source string, data []byte) ([]Payload, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return gunzip(source, data)
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return unzip(source, data)
+	case isDocument(data):
+		return []Payload{{Source: source, Data: data}}, nil
+	}
+
+	msg, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		// Neither a report nor a message, e.g. a stray file in the directory.
+		return nil, nil
+	}
+
+	return decodePart(source, msg.Header, msg.Body)
+}
+
+func isDocument(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	return bytes.HasPrefix(trimmed, []byte("<")) || bytes.HasPrefix(trimmed, []byte("{"))
+}
+
+type header interface {
+	Get(key string) string
+}
+
+func decodePart(source string, h header, body io.Reader) ([]Payload, error) {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var res []Payload
+
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return res, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading mime part: %w", err)
+			}
+
+			payloads, err := decodePart(source, part.Header, part)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, payloads...)
+		}
+	}
+
+	name := params["name"]
+	if _, dparams, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil && dparams["filename"] != "" {
+		name = dparams["filename"]
+	}
+
+	if name == "" && (strings.HasPrefix(mediaType, "text/") || strings.HasPrefix(mediaType, "message/")) {
+		return nil, nil
+	}
+
+	switch strings.ToLower(h.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	data, err := readLimited(body, maxPayload)
+	if err != nil {
+		return nil, fmt.Errorf("decoding attachment %q: %w", name, err)
+	}
+
+	if name != "" {
+		source += "!" + name
+	}
+
+	return Decode(source, data)
+}
+
+func gunzip(source string, data []byte) ([]Payload, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("opening gzip: %w", err)
+	}
+	defer zr.Close()
+
+	buf, err := readLimited(zr, maxPayload)
+	if err != nil {
+		return nil, fmt.Errorf("reading gzip: %w", err)
+	}
+
+	return []Payload{{Source: source, Data: buf}}, nil
+}
+
+func unzip(source string, data []byte) ([]Payload, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("opening zip: %w", err)
+	}
+
+	var res []Payload
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", f.Name, err)
+		}
+
+		buf, err := readLimited(rc, maxPayload)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", f.Name, err)
+		}
+
+		res = append(res, Payload{Source: source + "!" + f.Name, Data: buf})
+	}
+
+	return res, nil
+}
+
+// readLimited reads r to the end, failing with ErrPayloadTooLarge rather
+// than returning a cut-off document when it holds more than limit bytes.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrPayloadTooLarge, limit)
+	}
+
+	return data, nil
+}
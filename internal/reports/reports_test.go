@@ -0,0 +1,167 @@
+package reports
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDoc = `<?xml version="1.0"?><feedback></feedback>`
+
+func gzipped(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipped(t *testing.T, name, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func message(attachment []byte, name string) []byte {
+	encoded := base64.StdEncoding.EncodeToString(attachment)
+
+	var wrapped strings.Builder
+	for len(encoded) > 76 {
+		wrapped.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	wrapped.WriteString(encoded + "\r\n")
+
+	return []byte("From: noreply-dmarc-support@google.com\r\n" +
+		"To: postmaster@example.com\r\n" +
+		"Subject: Report domain: example.com\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"This is an aggregate report.\r\n" +
+		"--b1\r\n" +
+		"Content-Type: application/gzip; name=\"" + name + "\"\r\n" +
+		"Content-Disposition: attachment; filename=\"" + name + "\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		wrapped.String() +
+		"--b1--\r\n")
+}
+
+func TestDecode(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"raw":     []byte(testDoc),
+		"gzip":    gzipped(t, testDoc),
+		"zip":     zipped(t, "report.xml", testDoc),
+		"message": message(gzipped(t, testDoc), "report.xml.gz"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			payloads, err := Decode(name, data)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if len(payloads) != 1 || string(payloads[0].Data) != testDoc {
+				t.Errorf("Unexpected payloads: %+v", payloads)
+			}
+		})
+	}
+}
+
+func TestRead_Maildir(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string][]byte{
+		"cur/1700000000.M1.host:2,S": message(gzipped(t, testDoc), "a.xml.gz"),
+		"new/1700000001.M2.host":     message(zipped(t, "b.xml", testDoc), "b.zip"),
+		"tmp/1700000002.M3.host":     message(gzipped(t, testDoc), "partial.xml.gz"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	payloads, err := Read(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(payloads) != 2 {
+		t.Fatalf("Expected 2 payloads, got %d", len(payloads))
+	}
+
+	if !strings.HasSuffix(payloads[1].Source, "!b.zip!b.xml") {
+		t.Errorf("Unexpected source %q", payloads[1].Source)
+	}
+}
+
+func TestRead_BadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string][]byte{
+		"a.xml":    []byte(testDoc),
+		"b.xml.gz": {0x1f, 0x8b, 0x08, 0x00, 'x'},
+		"c.xml.gz": gzipped(t, testDoc),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	payloads, err := Read(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(payloads) != 3 || payloads[0].Err != nil || payloads[2].Err != nil {
+		t.Fatalf("Expected the good files to be read, got %+v", payloads)
+	}
+
+	if bad := payloads[1]; bad.Err == nil || !strings.HasSuffix(bad.Source, "b.xml.gz") {
+		t.Errorf("Expected an error for the corrupt file, got %+v", bad)
+	}
+}
+
+func TestReadLimited(t *testing.T) {
+	if data, err := readLimited(strings.NewReader("12345"), 5); err != nil || string(data) != "12345" {
+		t.Errorf("Expected the whole payload, got %q, %v", data, err)
+	}
+
+	if _, err := readLimited(strings.NewReader("123456"), 5); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package systemd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const DefaultUnitDir = "/etc/systemd/system"
+
+// Unit describes a simple long-running service unit.
+type Unit struct {
+	Name            string
+	Description     string
+	ExecStart       []string
+	EnvironmentFile string
+	User            string
+	Restart         string
+	RestartSec      int
+}
+
+func (u Unit) FileName() string {
+	if strings.HasSuffix(u.Name, ".service") {
+		return u.Name
+	}
+	return u.Name + ".service"
+}
+
+func (u Unit) RestartPolicy() string {
+	if u.Restart == "" {
+		return "on-failure"
+	}
+	return u.Restart
+}
+
+func (u Unit) Render(w io.Writer) error {
+	if len(u.ExecStart) == 0 {
+		return fmt.Errorf("unit %q has no ExecStart command", u.Name)
+	}
+	return tmpl.Execute(w, u)
+}
+
+// Install writes the unit file into dir, which defaults to DefaultUnitDir.
+func (u Unit) Install(dir string) (string, error) {
+	if dir == "" {
+		dir = DefaultUnitDir
+	}
+
+	path := filepath.Join(dir, u.FileName())
+
+	f, err := os.Create(path)
+	if err != nil {
+		return path, fmt.Errorf("creating unit file %q: %w", path, err)
+	}
+	defer f.Close()
+
+	if err = u.Render(f); err != nil {
+		return path, fmt.Errorf("writing unit file %q: %w", path, err)
+	}
+
+	return path, f.Close()
+}
+
+// Command quotes args for an Exec*= line.
+func Command(args []string) string {
+	var quoted []string
+	for _, a := range args {
+		if a != "" && !strings.ContainsAny(a, " \t\"'\\$%;") {
+			quoted = append(quoted, a)
+			continue
+		}
+
+		a = strings.ReplaceAll(a, `\`, `\\`)
+		a = strings.ReplaceAll(a, `"`, `\"`)
+		a = strings.ReplaceAll(a, `$`, `$$`)
+		a = strings.ReplaceAll(a, `%`, `%%`)
+		quoted = append(quoted, `"`+a+`"`)
+	}
+	return strings.Join(quoted, " ")
+}
+
+var tmpl = template.Must(template.New("").Funcs(template.FuncMap{"command": Command}).Parse(unitTemplate))
+
+const unitTemplate = `[Unit]
+Description={{ .Description }}
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=simple
+ExecStart={{ command .ExecStart }}
+{{- if .EnvironmentFile }}
+EnvironmentFile=-{{ .EnvironmentFile }}
+{{- end }}
+{{- if .User }}
+User={{ .User }}
+{{- end }}
+Restart={{ .RestartPolicy }}
+{{- if .RestartSec }}
+RestartSec={{ .RestartSec }}
+{{- end }}
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// WriteEnvironmentFile writes env as an EnvironmentFile= file readable only
+// by its owner, since it usually carries credentials.
+func WriteEnvironmentFile(path string, env map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating directory for %q: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", k, strconv.Quote(env[k]))
+	}
+
+	// Write a new file and rename it over path, so the credentials never
+	// land in an existing file with a looser mode.
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing environment file %q: %w", path, err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(b.String()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing environment file %q: %w", path, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing environment file %q: %w", path, err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("writing environment file %q: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package systemd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnit_Render(t *testing.T) {
+	u := Unit{
+		Name:            "cloud-init-helper-ddns",
+		Description:     "Dynamic DNS",
+		ExecStart:       []string{"/usr/local/bin/cloud-init-helper", "dns", "ddns", "--name", "host.example.com"},
+		EnvironmentFile: "/etc/cloud-init-helper/ddns.env",
+		RestartSec:      10,
+	}
+
+	var b strings.Builder
+	if err := u.Render(&b); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, want := range []string{
+		"Description=Dynamic DNS\n",
+		"ExecStart=/usr/local/bin/cloud-init-helper dns ddns --name host.example.com\n",
+		"EnvironmentFile=-/etc/cloud-init-helper/ddns.env\n",
+		"Restart=on-failure\n",
+		"RestartSec=10\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected unit to contain %q, got:\n%s", want, b.String())
+		}
+	}
+
+	if strings.Contains(b.String(), "User=") {
+		t.Error("Expected no User= line when no user is set")
+	}
+
+	if u.FileName() != "cloud-init-helper-ddns.service" {
+		t.Errorf("Unexpected file name %q", u.FileName())
+	}
+}
+
+func TestCommand(t *testing.T) {
+	got := Command([]string{"/bin/sh", "-c", `echo "$HOME" 100%`})
+	want := `/bin/sh -c "echo \"$$HOME\" 100%%"`
+	if got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestWriteEnvironmentFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "test.env")
+
+	if err := WriteEnvironmentFile(path, map[string]string{"TOKEN": "abc", "DNS_PROVIDER": "cloudflare"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != "DNS_PROVIDER=\"cloudflare\"\nTOKEN=\"abc\"\n" {
+		t.Errorf("Unexpected environment file:\n%s", buf)
+	}
+
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v (%v)", fi.Mode().Perm(), err)
+	}
+}
+
+func TestWriteEnvironmentFile_Existing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.env")
+	if err := os.WriteFile(path, []byte("OLD=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteEnvironmentFile(path, map[string]string{"TOKEN": "abc"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v (%v)", fi.Mode().Perm(), err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected only the environment file to be left, got %v (%v)", entries, err)
+	}
+}
@@ -0,0 +1,186 @@
+package tlsrpt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrNotTLSReport = errors.New("not a tls-rpt report")
+
+// Report is an RFC 8460 SMTP TLS report.
+type Report struct {
+	OrganizationName string    `json:"organization-name"`
+	DateRange        DateRange `json:"date-range"`
+	ContactInfo      string    `json:"contact-info"`
+	ReportID         string    `json:"report-id"`
+	Policies         []Policy  `json:"policies"`
+}
+
+type DateRange struct {
+	Start time.Time `json:"start-datetime"`
+	End   time.Time `json:"end-datetime"`
+}
+
+type Policy struct {
+	Policy         PolicyDetails   `json:"policy"`
+	Summary        PolicySummary   `json:"summary"`
+	FailureDetails []FailureDetail `json:"failure-details"`
+}
+
+type PolicyDetails struct {
+	Type   string     `json:"policy-type"`
+	String stringList `json:"policy-string"`
+	Domain string     `json:"policy-domain"`
+	MXHost stringList `json:"mx-host"`
+}
+
+type PolicySummary struct {
+	Successful int `json:"total-successful-session-count"`
+	Failed     int `json:"total-failure-session-count"`
+}
+
+type FailureDetail struct {
+	ResultType            string `json:"result-type"`
+	SendingMTAIP          string `json:"sending-mta-ip"`
+	ReceivingMXHostname   string `json:"receiving-mx-hostname"`
+	ReceivingMXHelo       string `json:"receiving-mx-helo"`
+	ReceivingIP           string `json:"receiving-ip"`
+	FailedSessionCount    int    `json:"failed-session-count"`
+	AdditionalInformation string `json:"additional-information"`
+	FailureReasonCode     string `json:"failure-reason-code"`
+}
+
+// stringList accepts a single string where the RFC asks for a list, which
+// some reporters send for mx-host.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = stringList{s}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	*l = list
+	return nil
+}
+
+// Parse decodes a TLS report document.
+func Parse(data []byte) (*Report, error) {
+	if !bytes.Contains(data, []byte(`"policies"`)) {
+		return nil, ErrNotTLSReport
+	}
+
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parsing tls report: %w", err)
+	}
+
+	return &r, nil
+}
+
+// Domain summarizes the TLS sessions reported for one policy domain.
+type Domain struct {
+	Domain       string         `json:"domain"`
+	Successful   int            `json:"successful"`
+	Failed       int            `json:"failed"`
+	PolicyTypes  map[string]int `json:"policy_types"`
+	FailureTypes map[string]int `json:"failure_types"`
+	SendingMTAs  map[string]int `json:"sending_mtas"`
+	ReceivingMXs map[string]int `json:"receiving_mxs"`
+	Reporters    []string       `json:"reporters"`
+}
+
+type Summary struct {
+	Reports int       `json:"reports"`
+	Begin   time.Time `json:"begin"`
+	End     time.Time `json:"end"`
+	Domains []*Domain `json:"domains"`
+}
+
+// Summarize aggregates reports by policy domain. Policy types count the
+// sessions evaluated under each type; the other maps count failed sessions.
+func Summarize(reports []*Report) *Summary {
+	s := &Summary{Reports: len(reports)}
+	domains := map[string]*Domain{}
+
+	for _, r := range reports {
+		if s.Begin.IsZero() || r.DateRange.Start.Before(s.Begin) {
+			s.Begin = r.DateRange.Start
+		}
+		if r.DateRange.End.After(s.End) {
+			s.End = r.DateRange.End
+		}
+
+		for _, p := range r.Policies {
+			name := strings.ToLower(p.Policy.Domain)
+
+			d, ok := domains[name]
+			if !ok {
+				d = &Domain{
+					Domain:       name,
+					PolicyTypes:  map[string]int{},
+					FailureTypes: map[string]int{},
+					SendingMTAs:  map[string]int{},
+					ReceivingMXs: map[string]int{},
+				}
+				domains[name] = d
+			}
+
+			d.Successful += p.Summary.Successful
+			d.Failed += p.Summary.Failed
+			d.PolicyTypes[p.Policy.Type] += p.Summary.Successful + p.Summary.Failed
+
+			for _, f := range p.FailureDetails {
+				d.FailureTypes[f.ResultType] += f.FailedSessionCount
+				if f.SendingMTAIP != "" {
+					d.SendingMTAs[f.SendingMTAIP] += f.FailedSessionCount
+				}
+				if f.ReceivingMXHostname != "" {
+					d.ReceivingMXs[strings.ToLower(f.ReceivingMXHostname)] += f.FailedSessionCount
+				}
+			}
+
+			if r.OrganizationName != "" && !slices.Contains(d.Reporters, r.OrganizationName) {
+				d.Reporters = append(d.Reporters, r.OrganizationName)
+				sort.Strings(d.Reporters)
+			}
+		}
+	}
+
+	for _, d := range domains {
+		s.Domains = append(s.Domains, d)
+	}
+
+	sort.Slice(s.Domains, func(i, j int) bool { return s.Domains[i].Domain < s.Domains[j].Domain })
+
+	return s
+}
+
+// Keys returns the keys of counts ordered by count, highest first.
+func Keys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
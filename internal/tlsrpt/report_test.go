@@ -0,0 +1,66 @@
+package tlsrpt
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	data, err := os.ReadFile("testdata/report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if r.OrganizationName != "Google Inc." || len(r.Policies) != 2 {
+		t.Fatalf("Unexpected report: %+v", r)
+	}
+
+	if mx := r.Policies[1].Policy.MXHost; !slices.Equal(mx, []string{"mx1.example.com"}) {
+		t.Errorf("Expected a single mx-host string to be accepted, got %v", mx)
+	}
+
+	if _, err := Parse([]byte(`<feedback/>`)); !errors.Is(err, ErrNotTLSReport) {
+		t.Errorf("Expected ErrNotTLSReport, got %v", err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	data, err := os.ReadFile("testdata/report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Summarize([]*Report{r, r})
+	if s.Reports != 2 || len(s.Domains) != 2 {
+		t.Fatalf("Unexpected summary: %+v", s)
+	}
+
+	d := s.Domains[0]
+	if d.Domain != "example.com" || d.Successful != 80 || d.Failed != 10 || d.PolicyTypes["sts"] != 90 {
+		t.Errorf("Unexpected domain summary: %+v", d)
+	}
+
+	if keys := Keys(d.FailureTypes); !slices.Equal(keys, []string{"certificate-expired", "sts-policy-fetch-error"}) || d.FailureTypes["certificate-expired"] != 8 {
+		t.Errorf("Unexpected failure types: %v", d.FailureTypes)
+	}
+
+	if d.SendingMTAs["209.85.220.41"] != 8 || d.ReceivingMXs["mx1.example.com"] != 8 {
+		t.Errorf("Unexpected failing hosts: %v %v", d.SendingMTAs, d.ReceivingMXs)
+	}
+
+	if s.Domains[1].PolicyTypes["no-policy-found"] != 14 {
+		t.Errorf("Unexpected policy types: %v", s.Domains[1].PolicyTypes)
+	}
+}